@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"defect-tracker/internal/pkg/config"
+	"defect-tracker/internal/pkg/storage"
+	"defect-tracker/internal/service/blobgc"
+	"defect-tracker/internal/service/blobmigrate"
+	"defect-tracker/internal/service/user"
+)
+
+type commandDeps struct {
+	cfg           config.Config
+	storage       storage.Provider
+	blobCollector *blobgc.Service
+	blobMigrator  *blobmigrate.Service
+	users         *user.Service
+}
+
+// runCommand executes a maintenance subcommand, e.g. `defect-tracker gc-blobs -dry-run`.
+func runCommand(ctx context.Context, name string, args []string, deps commandDeps) error {
+	switch name {
+	case "gc-blobs":
+		return runBlobGC(ctx, args, deps)
+	case "migrate-blobs":
+		return runBlobMigration(ctx, args, deps)
+	case "encrypt-blobs":
+		return runBlobEncryption(ctx, args, deps)
+	case "set-role":
+		return runSetRole(ctx, args, deps)
+	default:
+		return fmt.Errorf("unknown command %q (available: gc-blobs, migrate-blobs, encrypt-blobs, set-role)", name)
+	}
+}
+
+func runBlobGC(ctx context.Context, args []string, deps commandDeps) error {
+	flags := flag.NewFlagSet("gc-blobs", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", deps.cfg.Storage.GC.DryRun, "only report orphaned blobs")
+	grace := flags.Duration("grace", deps.cfg.Storage.GC.GracePeriod, "skip blobs younger than this")
+	mode := flags.String("mode", deps.cfg.Storage.GC.Mode, "quarantine or delete")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *mode != blobgc.ModeQuarantine && *mode != blobgc.ModeDelete {
+		return fmt.Errorf("unknown mode %q", *mode)
+	}
+
+	report, err := deps.blobCollector.Run(ctx, blobgc.Options{GracePeriod: *grace, Mode: *mode, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, orphan := range report.Orphans {
+		fmt.Fprintf(out, "%s\t%d\t%s\n", orphan.Key, orphan.Size, orphan.ModTime.Format(time.RFC3339))
+	}
+	out.Flush()
+
+	action := "removed"
+	if report.Mode == blobgc.ModeQuarantine {
+		action = "quarantined"
+	}
+	if report.DryRun {
+		action = "would be " + action + " (dry run)"
+	}
+	fmt.Printf("scanned %d blobs: %d referenced, %d within grace period, %d orphaned (%d bytes) %s",
+		report.Scanned, report.Referenced, report.TooYoung, len(report.Orphans), report.OrphanBytes, action)
+	if !report.DryRun {
+		fmt.Printf(", done %d, failed %d, stale blob records %d", report.Removed, report.Failed, report.StaleBlobs)
+	}
+	fmt.Println()
+	if report.Failed > 0 {
+		return fmt.Errorf("%d blobs could not be disposed", report.Failed)
+	}
+	return nil
+}
+
+// runBlobMigration copies attachment blobs between drivers, e.g. before switching
+// STORAGE_DRIVER from local to s3. Both drivers are configured by the usual
+// STORAGE_* variables; -local-path overrides STORAGE_PATH for the local side.
+func runBlobMigration(ctx context.Context, args []string, deps commandDeps) error {
+	flags := flag.NewFlagSet("migrate-blobs", flag.ContinueOnError)
+	from := flags.String("from", deps.cfg.Storage.Driver, "source driver: local or s3")
+	to := flags.String("to", "", "target driver: local or s3")
+	localPath := flags.String("local-path", deps.cfg.Storage.Path, "directory of the local driver")
+	keyPrefix := flags.String("key-prefix", "", "prepend to keys in the target and update attachments")
+	dryRun := flags.Bool("dry-run", false, "only report what would be copied")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" || *to == *from {
+		return fmt.Errorf("-to must name a driver other than -from")
+	}
+
+	cfg := deps.cfg
+	cfg.Storage.Path = *localPath
+	source, err := initStorage(cfg, *from)
+	if err != nil {
+		return fmt.Errorf("source storage: %w", err)
+	}
+	target, err := initStorage(cfg, *to)
+	if err != nil {
+		return fmt.Errorf("target storage: %w", err)
+	}
+
+	opts := blobmigrate.Options{KeyPrefix: *keyPrefix, DryRun: *dryRun}
+	report, err := deps.blobMigrator.Run(ctx, source, target, opts, func(p blobmigrate.Progress) {
+		line := fmt.Sprintf("[%d/%d] %s %s (%d bytes)", p.Index, p.Total, p.Status, p.Key, p.Bytes)
+		if p.Err != nil {
+			line += ": " + p.Err.Error()
+		}
+		fmt.Println(line)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d blobs: %d copied (%d bytes), %d already in target or planned, %d missing in source, %d failed\n",
+		report.Total, report.Copied, report.Bytes, report.Skipped, report.Missing, report.Failed)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d blobs were not migrated, run the command again after fixing the cause", report.Failed)
+	}
+	return nil
+}
+
+// runBlobEncryption brings every file of the local driver under the active
+// master key: files stored before encryption was enabled are encrypted and
+// files under a rotated-out key are re-encrypted. After it reports nothing
+// left, the old key can be removed from STORAGE_ENCRYPTION_KEYS.
+func runBlobEncryption(ctx context.Context, args []string, deps commandDeps) error {
+	flags := flag.NewFlagSet("encrypt-blobs", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report files that need encryption")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	local, ok := deps.storage.(*storage.Local)
+	if !ok || len(deps.cfg.Storage.EncryptionKeys) == 0 {
+		return fmt.Errorf("encryption requires STORAGE_DRIVER=local and STORAGE_ENCRYPTION_KEYS")
+	}
+
+	var keys []string
+	err := local.List(ctx, "", func(info storage.ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	failed := 0
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		status, err := local.Reencrypt(ctx, key, *dryRun)
+		if err != nil {
+			failed++
+			fmt.Printf("[%d/%d] failed %s: %v\n", i+1, len(keys), key, err)
+			continue
+		}
+		counts[status]++
+		if status != storage.ReencryptCurrent {
+			fmt.Printf("[%d/%d] %s %s\n", i+1, len(keys), status, key)
+		}
+	}
+
+	suffix := ""
+	if *dryRun {
+		suffix = " (dry run)"
+	}
+	fmt.Printf("%d files: %d encrypted, %d re-encrypted, %d already under the active key, %d failed%s\n",
+		len(keys), counts[storage.ReencryptEncrypted], counts[storage.ReencryptReencrypted], counts[storage.ReencryptCurrent], failed, suffix)
+	if failed > 0 {
+		return fmt.Errorf("%d files were not encrypted", failed)
+	}
+	return nil
+}
+
+// runSetRole changes the role of an account by email. The admin API needs an
+// administrator to begin with, so the first one is appointed this way.
+func runSetRole(ctx context.Context, args []string, deps commandDeps) error {
+	flags := flag.NewFlagSet("set-role", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the account")
+	role := flags.String("role", "admin", "admin, manager, engineer or observer")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+
+	account, err := deps.users.SetRole(ctx, *email, *role)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", account.Email, account.Role)
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,13 +14,20 @@ import (
 	"defect-tracker/internal/pkg/auth"
 	"defect-tracker/internal/pkg/config"
 	"defect-tracker/internal/pkg/logger"
+	"defect-tracker/internal/pkg/mailer"
+	"defect-tracker/internal/pkg/scanner"
 	"defect-tracker/internal/pkg/server"
 	"defect-tracker/internal/pkg/storage"
 	"defect-tracker/internal/repo/postgres"
+	"defect-tracker/internal/service/blobgc"
+	"defect-tracker/internal/service/blobmigrate"
 	"defect-tracker/internal/service/defect"
 	"defect-tracker/internal/service/project"
+	"defect-tracker/internal/service/scan"
 	"defect-tracker/internal/service/token"
+	"defect-tracker/internal/service/upload"
 	"defect-tracker/internal/service/user"
+	"defect-tracker/internal/service/workflow"
 	transporthttp "defect-tracker/internal/transport/http"
 	"defect-tracker/internal/transport/http/handlers"
 	"defect-tracker/internal/transport/http/middleware"
@@ -40,43 +48,90 @@ func main() {
 	pool := initDatabase(log, cfg.Database.DSN)
 	defer pool.Close()
 
-	var fileStorage storage.Provider
-	switch cfg.Storage.Driver {
-	case "s3":
-		fileStorage, err = storage.NewS3(storage.S3Config{
-			Endpoint:   cfg.Storage.S3.Endpoint,
-			AccessKey:  cfg.Storage.S3.AccessKey,
-			SecretKey:  cfg.Storage.S3.SecretKey,
-			Bucket:     cfg.Storage.S3.Bucket,
-			Region:     cfg.Storage.S3.Region,
-			UseSSL:     cfg.Storage.S3.UseSSL,
-			PresignTTL: cfg.Storage.S3.PresignTTL,
-		})
-	default:
-		fileStorage, err = storage.NewLocal(cfg.Storage.Path)
-	}
+	fileStorage, err := initStorage(cfg, cfg.Storage.Driver)
 	if err != nil {
 		log.Fatal("failed to init storage", zap.Error(err))
 	}
 
+	defectRepo := postgres.NewDefectRepository(pool)
 	userRepo := postgres.NewUserRepository(pool)
-	userService := user.NewService(userRepo)
+	userService := user.NewService(userRepo, user.Options{OpenRegistration: cfg.Auth.OpenRegistration})
+	blobCollector := blobgc.NewService(defectRepo, fileStorage, log)
+
+	// Maintenance subcommands share the configuration and exit instead of serving HTTP.
+	if len(os.Args) > 1 {
+		cmdCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		deps := commandDeps{
+			cfg:           cfg,
+			storage:       fileStorage,
+			blobCollector: blobCollector,
+			blobMigrator:  blobmigrate.NewService(defectRepo, log),
+			users:         userService,
+		}
+		if err := runCommand(cmdCtx, os.Args[1], os.Args[2:], deps); err != nil {
+			log.Fatal("command failed", zap.String("command", os.Args[1]), zap.Error(err))
+		}
+		return
+	}
+
 	tokenManager := auth.NewManager(cfg.Auth.Secret, cfg.Auth.AccessTTL)
 	tokenRepo := postgres.NewTokenRepository(pool)
 	tokenService := token.NewService(tokenRepo, cfg.Auth.RefreshTTL)
 
-	defectRepo := postgres.NewDefectRepository(pool)
-	defectService := defect.NewService(defectRepo)
-	defectHandler := handlers.NewDefectHandler(defectService, fileStorage)
+	txManager := postgres.NewTxManager(pool)
 
 	projectRepo := postgres.NewProjectRepository(pool)
 	projectService := project.NewService(projectRepo)
 	projectHandler := handlers.NewProjectHandler(projectService)
 
-	authHandler := handlers.NewAuthHandler(userService, tokenService, tokenManager)
+	workflowRepo := postgres.NewWorkflowRepository(pool)
+	workflowService := workflow.NewService(workflowRepo, txManager, projectService)
+	workflowHandler := handlers.NewWorkflowHandler(workflowService)
+
+	defectService := defect.NewService(defectRepo, txManager, workflowService, projectService, defect.AttachmentLimits{
+		MaxFileSize:          cfg.Storage.MaxFileSize,
+		MaxResumableFileSize: cfg.Storage.Uploads.MaxFileSize,
+		MaxDefectSize:        cfg.Storage.MaxDefectSize,
+	})
+	uploadService := upload.NewService(postgres.NewUploadRepository(pool), defectService, fileStorage, upload.Options{
+		ChunkSize:    cfg.Storage.Uploads.ChunkSize,
+		TTL:          cfg.Storage.Uploads.TTL,
+		ChunkTimeout: cfg.Storage.Uploads.ChunkTimeout,
+	}, log)
+	malwareScanner, err := initScanner(cfg, log)
+	if err != nil {
+		log.Fatal("failed to init malware scanner", zap.Error(err))
+	}
+	scanOptions := scan.Options{}
+	if cfg.Scan.Driver == "clamd" {
+		scanOptions.MaxFileSize = cfg.Scan.MaxFileSize
+	}
+	scanService := scan.NewService(defectRepo, malwareScanner, fileStorage, scanOptions, log)
+	defectHandler := handlers.NewDefectHandler(defectService, uploadService, scanService, fileStorage)
+
+	mail, err := initMailer(cfg, log)
+	if err != nil {
+		log.Fatal("failed to init mailer", zap.Error(err))
+	}
+	resetService := user.NewResetService(userRepo, mail, user.ResetOptions{
+		TTL: cfg.Auth.PasswordResetTTL,
+		URL: cfg.Auth.PasswordResetURL,
+	}, log)
+
+	invitationService := user.NewInvitationService(postgres.NewInvitationRepository(pool), txManager, projectService, mail, user.InvitationOptions{
+		Secret: cfg.Auth.Secret,
+		TTL:    cfg.Auth.InvitationTTL,
+		URL:    cfg.Auth.InvitationURL,
+	}, log)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+
+	authHandler := handlers.NewAuthHandler(userService, resetService, invitationService, tokenService, tokenManager)
 	authMiddleware := middleware.NewAuthMiddleware(tokenManager, userService)
 
-	router := transporthttp.NewRouter(cfg.AppName, authHandler, authMiddleware, defectHandler, projectHandler)
+	adminHandler := handlers.NewAdminHandler(userService, resetService, tokenService)
+
+	router := transporthttp.NewRouter(cfg.AppName, authHandler, authMiddleware, defectHandler, projectHandler, workflowHandler, invitationHandler, adminHandler)
 	httpServer := server.NewHTTPServer(cfg, router, log)
 
 	go func() {
@@ -85,9 +140,85 @@ func main() {
 		}
 	}()
 
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go uploadService.PurgeExpiredEvery(workersCtx, time.Hour)
+	go scanService.ScanPendingEvery(workersCtx, cfg.Scan.RetryInterval)
+	if cfg.Storage.GC.Enabled {
+		go blobCollector.RunEvery(workersCtx, cfg.Storage.GC.Interval, blobgc.Options{
+			GracePeriod: cfg.Storage.GC.GracePeriod,
+			Mode:        cfg.Storage.GC.Mode,
+			DryRun:      cfg.Storage.GC.DryRun,
+		})
+	}
+
 	waitForShutdown(log, httpServer)
 }
 
+// initStorage creates the provider of the given driver, "local" or "s3".
+func initStorage(cfg config.Config, driver string) (storage.Provider, error) {
+	switch driver {
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:   cfg.Storage.S3.Endpoint,
+			AccessKey:  cfg.Storage.S3.AccessKey,
+			SecretKey:  cfg.Storage.S3.SecretKey,
+			Bucket:     cfg.Storage.S3.Bucket,
+			Region:     cfg.Storage.S3.Region,
+			UseSSL:     cfg.Storage.S3.UseSSL,
+			PresignTTL: cfg.Storage.S3.PresignTTL,
+		})
+	case "local", "":
+		if len(cfg.Storage.EncryptionKeys) == 0 {
+			return storage.NewLocal(cfg.Storage.Path)
+		}
+		keys, err := storage.ParseKeyring(cfg.Storage.EncryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("encryption keys: %w", err)
+		}
+		return storage.NewEncryptedLocal(cfg.Storage.Path, keys)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+// initScanner creates the malware scanner of SCAN_DRIVER, "clamd" or "none".
+// An unreachable daemon is not fatal: uploads wait in pending_scan until it is back.
+func initScanner(cfg config.Config, log *zap.Logger) (scanner.Scanner, error) {
+	switch cfg.Scan.Driver {
+	case "clamd":
+		clamd := scanner.NewClamd(cfg.Scan.ClamdAddress, cfg.Scan.Timeout)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := clamd.Ping(ctx); err != nil {
+			log.Warn("clamd is not reachable", zap.String("address", cfg.Scan.ClamdAddress), zap.Error(err))
+		}
+		return clamd, nil
+	case "none", "":
+		return scanner.Noop{}, nil
+	default:
+		return nil, fmt.Errorf("unknown scan driver %q", cfg.Scan.Driver)
+	}
+}
+
+// initMailer creates the mailer of MAIL_DRIVER, "smtp" or "log".
+func initMailer(cfg config.Config, log *zap.Logger) (mailer.Mailer, error) {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     cfg.Mail.SMTP.Host,
+			Port:     cfg.Mail.SMTP.Port,
+			Username: cfg.Mail.SMTP.Username,
+			Password: cfg.Mail.SMTP.Password,
+			From:     cfg.Mail.From,
+		})
+	case "log", "":
+		return mailer.NewLog(log), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Mail.Driver)
+	}
+}
+
 func waitForShutdown(log *zap.Logger, srv *server.HTTPServer) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
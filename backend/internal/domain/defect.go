@@ -1,6 +1,12 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrConflict indicates that the defect was changed concurrently by another request.
+var ErrConflict = errors.New("defect was modified concurrently")
 
 // DefectListItem describes a subset of fields for table/list views.
 type DefectListItem struct {
@@ -13,7 +19,18 @@ type DefectListItem struct {
 	AssigneeID   string
 	AssigneeName string
 	DueDate      *time.Time
+	ReopenCount  int
 	UpdatedAt    time.Time
+	// Highlight is set only for full-text search results.
+	Highlight *DefectHighlight
+}
+
+// DefectHighlight holds search snippets with matches wrapped in <mark></mark>.
+// Fields are empty when the corresponding text has no match.
+type DefectHighlight struct {
+	Title       string
+	Description string
+	Comment     string
 }
 
 // Defect represents full defect entity with description/comments info.
@@ -29,11 +46,13 @@ type Defect struct {
 	AssigneeID  string
 	Assignee    string
 	DueDate     *time.Time
+	ReopenCount int
 	CreatedBy   string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	Attachments []Attachment
 	Comments    []Comment
+	History     []HistoryEntry
 }
 
 // DefectCreate describes payload for creating a defect.
@@ -45,17 +64,97 @@ type DefectCreate struct {
 	Severity    string
 	AssigneeID  string
 	DueDate     *time.Time
+	Status      string
 	CreatedBy   string
 }
 
-// DefectFilter keeps optional filters passed from transport layer.
+// StatusChange describes a workflow transition request. ExpectedStatus guards
+// against concurrent changes, Comment is stored as a defect comment and Reason
+// is kept with the history entry (e.g. why a fix was rejected or a defect reopened).
+type StatusChange struct {
+	Status         string
+	ExpectedStatus string
+	Comment        string
+	Reason         string
+}
+
+// DefectUpdate describes a partial defect update: nil fields stay untouched.
+// Empty AssigneeID unassigns the defect, ClearDueDate removes the deadline.
+type DefectUpdate struct {
+	Title        *string
+	Description  *string
+	Priority     *string
+	Severity     *string
+	AssigneeID   *string
+	DueDate      *time.Time
+	ClearDueDate bool
+	UpdatedBy    string
+}
+
+// DefectFilter keeps optional filters passed from transport layer. Values
+// inside one list are OR-ed, different fields are AND-ed. Date ranges are
+// inclusive on both ends.
 type DefectFilter struct {
-	Status   string
-	Priority string
-	Limit    int
-	Project  string
+	Statuses   []string
+	Priorities []string
+	Severities []string
+	Projects   []string
+	// Assignees may contain AssigneeMe and AssigneeNone besides user ids.
+	Assignees []string
+	// Creators may contain AssigneeMe besides user ids.
+	Creators    []string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	DueFrom     *time.Time
+	DueTo       *time.Time
+	// Overdue keeps defects past their due date that are not in a final status.
+	Overdue bool
+	// Query is a full-text search over title, description and comments.
+	Query string
+	// HasGeotaggedPhoto keeps defects with at least one photo carrying GPS coordinates.
+	HasGeotaggedPhoto bool
+	Limit             int
+	MemberID          string
+	Sort              string
+	SortDesc          bool
+	Cursor            string
 }
 
+// Special values accepted in DefectFilter.Assignees and DefectFilter.Creators.
+const (
+	AssigneeMe   = "me"
+	AssigneeNone = "unassigned"
+)
+
+// Sort keys supported by DefectFilter.
+const (
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortDueDate   = "due_date"
+	SortPriority  = "priority"
+	SortStatus    = "status"
+	// SortRelevance orders by full-text rank and requires DefectFilter.Query.
+	SortRelevance = "relevance"
+)
+
+// DefectPage is a keyset-paginated slice of defects. NextCursor is empty on the last page.
+type DefectPage struct {
+	Items      []DefectListItem
+	Total      int
+	NextCursor string
+}
+
+// ErrInvalidCursor is returned for malformed cursors or cursors issued for another sort order.
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// ErrDefectNotFound is returned for unknown or malformed defect ids.
+var ErrDefectNotFound = errors.New("defect not found")
+
+// ErrInvalidFilterID is returned when a project, assignee or creator filter holds a malformed id.
+var ErrInvalidFilterID = errors.New("invalid id in defect filter")
+
 type Comment struct {
 	ID         string
 	AuthorID   string
@@ -77,13 +176,146 @@ type Attachment struct {
 	ContentType string
 	SizeBytes   int64
 	StorageKey  string
-	UploadedAt  time.Time
+	// Checksum is the hex SHA-256 of the content; empty for files uploaded before it was recorded.
+	Checksum string
+	// HasThumbnails is set for images with previews stored under derived keys.
+	HasThumbnails bool
+	Photo         PhotoMetadata
+	UploadedBy    string
+	UploadedAt    time.Time
+	// Version starts at 1 and grows with every replacement; VersionOf is the id
+	// of the first version and is empty for the first version itself.
+	Version      int
+	VersionOf    string
+	SupersededAt *time.Time
+	// ScanStatus is one of the Scan* constants; only clean files are served.
+	// ScanSignature names the malware found in a quarantined file.
+	ScanStatus    string
+	ScanSignature string
+	ScannedAt     *time.Time
+}
+
+// Malware scan states of an attachment.
+const (
+	ScanPending     = "pending_scan"
+	ScanClean       = "clean"
+	ScanQuarantined = "quarantined"
+	// ScanMissing marks files whose blob was gone before it could be scanned.
+	ScanMissing = "missing"
+	// ScanTooLarge marks files above the size the scanner accepts.
+	ScanTooLarge = "too_large"
+)
+
+// CheckDownloadable returns ErrAttachmentNotScanned, ErrAttachmentQuarantined,
+// ErrAttachmentMissing or ErrAttachmentTooLargeToScan unless the file passed
+// the malware scan.
+func (a Attachment) CheckDownloadable() error {
+	switch a.ScanStatus {
+	case ScanClean:
+		return nil
+	case ScanQuarantined:
+		return ErrAttachmentQuarantined
+	case ScanMissing:
+		return ErrAttachmentMissing
+	case ScanTooLarge:
+		return ErrAttachmentTooLargeToScan
+	default:
+		return ErrAttachmentNotScanned
+	}
+}
+
+// RootID returns the id shared by all versions of the attachment.
+func (a Attachment) RootID() string {
+	if a.VersionOf != "" {
+		return a.VersionOf
+	}
+	return a.ID
+}
+
+// PhotoMetadata is taken from EXIF and proves when, where and with what a photo
+// was taken. Fields absent in the file stay nil/empty.
+type PhotoMetadata struct {
+	TakenAt     *time.Time
+	Latitude    *float64
+	Longitude   *float64
+	CameraModel string
+}
+
+// Geotagged reports whether the photo carries GPS coordinates.
+func (m PhotoMetadata) Geotagged() bool {
+	return m.Latitude != nil && m.Longitude != nil
 }
 
 type AttachmentCreate struct {
-	DefectID    string
-	Filename    string
-	ContentType string
-	SizeBytes   int64
-	StorageKey  string
+	DefectID      string
+	Filename      string
+	ContentType   string
+	SizeBytes     int64
+	StorageKey    string
+	Checksum      string
+	HasThumbnails bool
+	Photo         PhotoMetadata
+	UploadedBy    string
+	// ReplacesID uploads a new version of an existing attachment.
+	ReplacesID string
+}
+
+// ExportedAttachment is an attachment with the context an archive export needs.
+type ExportedAttachment struct {
+	Attachment
+	DefectTitle  string
+	UploaderName string
+}
+
+// Blob is a stored file shared by all attachments with the same content.
+// RefCount is the number of non-deleted attachment rows pointing to it; the
+// file may be purged once it drops to zero.
+type Blob struct {
+	StorageKey    string
+	Checksum      string
+	SizeBytes     int64
+	HasThumbnails bool
+	RefCount      int
+}
+
+// Attachment errors.
+var (
+	ErrFileTooLarge              = errors.New("attachment exceeds the file size limit")
+	ErrAttachmentQuotaExceeded   = errors.New("attachments of the defect exceed the size quota")
+	ErrUnsupportedAttachmentType = errors.New("attachment type is not allowed")
+	ErrAttachmentForbidden       = errors.New("only the author or a project manager may change the attachment")
+	ErrAttachmentSuperseded      = errors.New("attachment was already replaced by a newer version")
+	ErrAttachmentNotScanned      = errors.New("attachment has not been scanned for malware yet")
+	ErrAttachmentQuarantined     = errors.New("attachment is quarantined as malware")
+	ErrAttachmentMissing         = errors.New("attachment content is missing from storage")
+	ErrAttachmentTooLargeToScan  = errors.New("attachment is too large for the malware scanner")
+)
+
+// HistoryEntry is a single audit record about a changed defect field.
+type HistoryEntry struct {
+	ID        string
+	DefectID  string
+	ActorID   string
+	ActorName string
+	Field     string
+	OldValue  string
+	NewValue  string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// Timeline event types.
+const (
+	TimelineHistory    = "history"
+	TimelineComment    = "comment"
+	TimelineAttachment = "attachment"
+)
+
+// TimelineEvent wraps one of history/comment/attachment records for the merged defect timeline.
+type TimelineEvent struct {
+	Type       string
+	CreatedAt  time.Time
+	History    *HistoryEntry
+	Comment    *Comment
+	Attachment *Attachment
 }
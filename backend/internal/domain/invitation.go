@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Invitation lets a person without an account join a project with a given
+// role. Accepting it creates the account and the project membership.
+type Invitation struct {
+	ID          string
+	Email       string
+	ProjectID   string
+	ProjectName string
+	Role        string
+	InvitedBy   string
+	ExpiresAt   time.Time
+	AcceptedAt  *time.Time
+	CreatedAt   time.Time
+}
+
+// InvitationCreate describes a new invitation issued by a project manager or an administrator.
+type InvitationCreate struct {
+	Email     string
+	ProjectID string
+	Role      string
+	InvitedBy string
+	ExpiresAt time.Time
+}
+
+// ErrInvitationInvalid is returned for forged, revoked, accepted or expired invitation tokens.
+var ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+
+// ErrInvitationNotFound is returned when the project has no such pending invitation.
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// ErrRegistrationClosed is returned by public registration when accounts are created by invitation only.
+var ErrRegistrationClosed = errors.New("registration is by invitation only")
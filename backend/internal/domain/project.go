@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type Project struct {
 	ID          string
@@ -9,6 +12,8 @@ type Project struct {
 	Description string
 	StartDate   *time.Time
 	EndDate     *time.Time
+	WorkflowID  string
+	ArchivedAt  *time.Time
 	CreatedBy   string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
@@ -22,3 +27,53 @@ type ProjectCreate struct {
 	EndDate     *time.Time
 	CreatedBy   string
 }
+
+// ProjectUpdate describes a partial project update: nil fields stay untouched.
+type ProjectUpdate struct {
+	Name           *string
+	Stage          *string
+	Description    *string
+	StartDate      *time.Time
+	EndDate        *time.Time
+	ClearStartDate bool
+	ClearEndDate   bool
+}
+
+// Project member roles, ordered by privileges.
+const (
+	ProjectRoleObserver = "observer"
+	ProjectRoleEngineer = "engineer"
+	ProjectRoleManager  = "manager"
+)
+
+// ProjectMember links a user to a project with a per-project role.
+type ProjectMember struct {
+	ProjectID string
+	UserID    string
+	FullName  string
+	Email     string
+	Role      string
+}
+
+// ErrNoProjectAccess indicates that the user is not a member of the project
+// or the per-project role is insufficient for the action.
+var ErrNoProjectAccess = errors.New("no access to project")
+
+// ErrProjectArchived is returned when changing defects of an archived project.
+var ErrProjectArchived = errors.New("project is archived")
+
+// ErrProjectHasOpenDefects prevents deleting projects with unfinished defects.
+var ErrProjectHasOpenDefects = errors.New("project has open defects")
+
+// ErrMemberNotFound is returned when the user is not a member of the project.
+var ErrMemberNotFound = errors.New("project member not found")
+
+// ProjectRoleAtLeast reports whether role grants at least the privileges of required.
+func ProjectRoleAtLeast(role, required string) bool {
+	rank := map[string]int{
+		ProjectRoleObserver: 1,
+		ProjectRoleEngineer: 2,
+		ProjectRoleManager:  3,
+	}
+	return rank[role] > 0 && rank[role] >= rank[required]
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Upload is a resumable attachment upload. The client sends the file in
+// chunks of ChunkSize bytes (the last one may be shorter); each chunk becomes a
+// part of a multipart upload in the storage. The attachment row is created
+// only once all SizeBytes have arrived.
+type Upload struct {
+	ID         string
+	DefectID   string
+	Filename   string
+	SizeBytes  int64
+	ChunkSize  int64
+	Offset     int64
+	ReplacesID string
+	// StorageKey and StorageUploadID identify the multipart upload in the storage.
+	StorageKey      string
+	StorageUploadID string
+	Parts           []UploadPart
+	// HashState is the serialized SHA-256 state after Offset bytes, so the
+	// checksum is computed while streaming without reading the file again.
+	HashState []byte
+	CreatedBy string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// UploadPart is a chunk already stored in the storage.
+type UploadPart struct {
+	Number    int
+	ETag      string
+	SizeBytes int64
+}
+
+// Complete reports whether every byte of the file has been received.
+func (u Upload) Complete() bool {
+	return u.Offset == u.SizeBytes
+}
+
+// Upload errors.
+var (
+	ErrUploadOffsetMismatch = errors.New("chunk offset does not match the upload offset")
+	ErrUploadChunkSize      = errors.New("chunk size does not match the negotiated chunk size")
+	ErrUploadBusy           = errors.New("another chunk of the upload is being written")
+	ErrUploadIncomplete     = errors.New("upload has not received all bytes yet")
+	ErrUploadExpired        = errors.New("upload has expired")
+)
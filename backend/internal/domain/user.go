@@ -12,8 +12,41 @@ type User struct {
 	FullName     string
 	Role         string
 	PasswordHash string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	// IsActive is cleared by an administrator to lock the user out.
+	IsActive  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// System-wide user roles. Administrators manage accounts; project work is
+// governed by project member roles.
+const (
+	RoleAdmin    = "admin"
+	RoleManager  = "manager"
+	RoleEngineer = "engineer"
+	RoleObserver = "observer"
+)
+
+// CanCreateProjects reports whether the user may create projects and
+// workflows: managers and administrators.
+func (u User) CanCreateProjects() bool {
+	return u.Role == RoleManager || u.Role == RoleAdmin
+}
+
+// UserFilter narrows the administrator's user list. Query matches email and
+// full name; a nil Active lists both active and deactivated users.
+type UserFilter struct {
+	Query  string
+	Role   string
+	Active *bool
+	Limit  int
+	Offset int
+}
+
+// UserAccountUpdate changes account settings; nil fields are left as is.
+type UserAccountUpdate struct {
+	Role     *string
+	IsActive *bool
 }
 
 type RefreshToken struct {
@@ -24,13 +57,22 @@ type RefreshToken struct {
 	CreatedAt time.Time
 }
 
-// UserRegister describes payload for public registration.
+// UserRegister describes payload for public registration. Self-registered
+// users are always engineers; other roles are granted by invitation.
 type UserRegister struct {
 	Email    string
 	FullName string
 	Password string
-	Role     string
 }
 
 // ErrEmailAlreadyExists indicates unique constraint violation for user email.
 var ErrEmailAlreadyExists = errors.New("user with email already exists")
+
+// ErrResetTokenInvalid is returned for unknown, used or expired password reset tokens.
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+// User account errors.
+var (
+	ErrUserInactive = errors.New("user account is deactivated")
+	ErrSelfLockout  = errors.New("administrators cannot demote or deactivate themselves")
+)
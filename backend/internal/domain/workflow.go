@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Fields that a workflow transition may require.
+const (
+	RequiredComment  = "comment"
+	RequiredAssignee = "assignee_id"
+	RequiredDueDate  = "due_date"
+	RequiredReason   = "reason"
+)
+
+// Workflow describes statuses and allowed transitions used by a project.
+type Workflow struct {
+	ID          string
+	Name        string
+	IsDefault   bool
+	CreatedBy   string
+	CreatedAt   time.Time
+	Statuses    []WorkflowStatus
+	Transitions []WorkflowTransition
+}
+
+type WorkflowStatus struct {
+	Code      string
+	Name      string
+	IsInitial bool
+	IsFinal   bool
+	Position  int
+}
+
+// WorkflowTransition allows moving a defect From -> To. Empty AllowedRoles means any role.
+type WorkflowTransition struct {
+	From           string
+	To             string
+	AllowedRoles   []string
+	RequiredFields []string
+}
+
+type WorkflowCreate struct {
+	Name        string
+	Statuses    []WorkflowStatus
+	Transitions []WorkflowTransition
+	CreatedBy   string
+}
+
+// ErrWorkflowMismatch is returned when project defects use statuses missing in the target workflow.
+var ErrWorkflowMismatch = errors.New("workflow does not contain statuses used by project defects")
+
+// InitialStatus returns the status assigned to newly created defects.
+func (w Workflow) InitialStatus() string {
+	for _, status := range w.Statuses {
+		if status.IsInitial {
+			return status.Code
+		}
+	}
+	return ""
+}
+
+func (w Workflow) HasStatus(code string) bool {
+	_, ok := w.Status(code)
+	return ok
+}
+
+func (w Workflow) Status(code string) (WorkflowStatus, bool) {
+	for _, status := range w.Statuses {
+		if status.Code == code {
+			return status, true
+		}
+	}
+	return WorkflowStatus{}, false
+}
+
+// IsReopen reports whether moving from -> to brings a finished defect back to work.
+func (w Workflow) IsReopen(from, to string) bool {
+	fromStatus, okFrom := w.Status(from)
+	toStatus, okTo := w.Status(to)
+	return okFrom && okTo && fromStatus.IsFinal && !toStatus.IsFinal
+}
+
+func (w Workflow) Transition(from, to string) (WorkflowTransition, bool) {
+	for _, transition := range w.Transitions {
+		if transition.From == from && transition.To == to {
+			return transition, true
+		}
+	}
+	return WorkflowTransition{}, false
+}
+
+// AllowsRole reports whether a user with the given role may perform the transition.
+func (t WorkflowTransition) AllowsRole(role string) bool {
+	if len(t.AllowedRoles) == 0 {
+		return true
+	}
+	for _, allowed := range t.AllowedRoles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (t WorkflowTransition) Requires(field string) bool {
+	for _, required := range t.RequiredFields {
+		if required == field {
+			return true
+		}
+	}
+	return false
+}
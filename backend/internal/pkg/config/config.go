@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/caarlos0/env/v10"
@@ -35,12 +36,73 @@ type Config struct {
 			UseSSL     bool          `env:"STORAGE_S3_USE_SSL" envDefault:"false"`
 			PresignTTL time.Duration `env:"STORAGE_S3_PRESIGN_TTL" envDefault:"15m"`
 		}
+
+		// EncryptionKeys enables encryption at rest for the local driver. Keys
+		// are "id:base64" of 32 bytes; the first encrypts new files, the rest
+		// only decrypt files written before a rotation.
+		EncryptionKeys []string `env:"STORAGE_ENCRYPTION_KEYS" envSeparator:","`
+
+		// Size limits in bytes: one file and all attachments of a defect. The
+		// defect quota also bounds resumable uploads, so it may not be smaller
+		// than Uploads.MaxFileSize.
+		MaxFileSize   int64 `env:"STORAGE_MAX_FILE_SIZE" envDefault:"26214400"`     // 25 MiB
+		MaxDefectSize int64 `env:"STORAGE_MAX_DEFECT_SIZE" envDefault:"4294967296"` // 4 GiB
+
+		// Uploads configures resumable chunked uploads, see service/upload.
+		Uploads struct {
+			MaxFileSize  int64         `env:"STORAGE_UPLOAD_MAX_FILE_SIZE" envDefault:"2147483648"` // 2 GiB
+			ChunkSize    int64         `env:"STORAGE_UPLOAD_CHUNK_SIZE" envDefault:"8388608"`       // 8 MiB, at least 5 MiB
+			TTL          time.Duration `env:"STORAGE_UPLOAD_TTL" envDefault:"24h"`
+			ChunkTimeout time.Duration `env:"STORAGE_UPLOAD_CHUNK_TIMEOUT" envDefault:"5m"`
+		}
+
+		// GC reconciles stored blobs with attachment rows, see service/blobgc.
+		GC struct {
+			Enabled     bool          `env:"STORAGE_GC_ENABLED" envDefault:"false"`
+			Interval    time.Duration `env:"STORAGE_GC_INTERVAL" envDefault:"24h"`
+			GracePeriod time.Duration `env:"STORAGE_GC_GRACE_PERIOD" envDefault:"72h"`
+			Mode        string        `env:"STORAGE_GC_MODE" envDefault:"quarantine"`
+			DryRun      bool          `env:"STORAGE_GC_DRY_RUN" envDefault:"false"`
+		}
+	}
+
+	// Scan configures malware scanning of uploads, see service/scan.
+	Scan struct {
+		Driver        string        `env:"SCAN_DRIVER" envDefault:"none"` // none or clamd
+		ClamdAddress  string        `env:"SCAN_CLAMD_ADDRESS" envDefault:"localhost:3310"`
+		Timeout       time.Duration `env:"SCAN_TIMEOUT" envDefault:"2m"`
+		RetryInterval time.Duration `env:"SCAN_RETRY_INTERVAL" envDefault:"5m"`
+		// MaxFileSize in bytes skips scanning larger files with clamd, which
+		// then cannot be downloaded; zero leaves it to the daemon's StreamMaxLength.
+		MaxFileSize int64 `env:"SCAN_MAX_FILE_SIZE" envDefault:"0"`
 	}
 
 	Auth struct {
 		Secret     string        `env:"JWT_SECRET,required"`
 		AccessTTL  time.Duration `env:"JWT_ACCESS_TTL" envDefault:"1h"`
 		RefreshTTL time.Duration `env:"JWT_REFRESH_TTL" envDefault:"720h"` // default 30 days
+
+		// PasswordResetURL is the web client page that emailed reset links open.
+		PasswordResetURL string        `env:"PASSWORD_RESET_URL" envDefault:"http://localhost:5173/"`
+		PasswordResetTTL time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"1h"`
+
+		// Accounts are created by invitation; OpenRegistration additionally
+		// lets anyone sign up as an engineer, e.g. on demo instances.
+		OpenRegistration bool          `env:"OPEN_REGISTRATION" envDefault:"false"`
+		InvitationURL    string        `env:"INVITATION_URL" envDefault:"http://localhost:5173/"`
+		InvitationTTL    time.Duration `env:"INVITATION_TTL" envDefault:"168h"` // 7 days
+	}
+
+	// Mail configures outgoing email; the log driver only writes messages to the log.
+	Mail struct {
+		Driver string `env:"MAIL_DRIVER" envDefault:"log"` // log or smtp
+		From   string `env:"MAIL_FROM" envDefault:"Контроль дефектов <noreply@defect-tracker.local>"`
+		SMTP   struct {
+			Host     string `env:"MAIL_SMTP_HOST"`
+			Port     int    `env:"MAIL_SMTP_PORT" envDefault:"587"`
+			Username string `env:"MAIL_SMTP_USERNAME"`
+			Password string `env:"MAIL_SMTP_PASSWORD"`
+		}
 	}
 }
 
@@ -50,5 +112,9 @@ func Load() (Config, error) {
 	if err := env.Parse(&cfg); err != nil {
 		return cfg, err
 	}
+	if cfg.Storage.MaxDefectSize > 0 && cfg.Storage.MaxDefectSize < cfg.Storage.Uploads.MaxFileSize {
+		return cfg, fmt.Errorf("STORAGE_MAX_DEFECT_SIZE (%d) is smaller than STORAGE_UPLOAD_MAX_FILE_SIZE (%d)",
+			cfg.Storage.MaxDefectSize, cfg.Storage.Uploads.MaxFileSize)
+	}
 	return cfg, nil
 }
@@ -0,0 +1,182 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/storage"
+)
+
+// ManifestName is the CSV listing every file of the archive.
+const ManifestName = "manifest.csv"
+
+var manifestHeader = []string{"defect_id", "defect_title", "path", "filename", "uploaded_by", "uploaded_at", "size_bytes", "sha256", "note"}
+
+// Notes for attachments left out of the archive.
+const (
+	noteMissing     = "файл отсутствует в хранилище"
+	noteNotScanned  = "файл ещё не проверен антивирусом"
+	noteQuarantined = "файл заблокирован антивирусом"
+	noteTooLarge    = "файл слишком большой для антивирусной проверки"
+)
+
+// AttachmentsZIP streams the attachments as a ZIP archive with a folder per
+// defect and a manifest at the end. Blobs are read one at a time, so the
+// archive never has to fit in memory or on disk. beforeFile, if set, is called
+// before each blob is copied, e.g. to extend the connection write deadline.
+func AttachmentsZIP(ctx context.Context, w io.Writer, files storage.Provider, items []domain.ExportedAttachment, beforeFile func()) error {
+	zw := zip.NewWriter(w)
+	rows := [][]string{manifestHeader}
+	folders := make(map[string]string)
+	used := make(map[string]bool)
+
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		folder, ok := folders[item.DefectID]
+		if !ok {
+			folder = uniqueName(used, defectFolder(item))
+			folders[item.DefectID] = folder
+		}
+		name := uniqueName(used, folder+"/"+safeName(item.Filename, "file"))
+
+		if beforeFile != nil {
+			beforeFile()
+		}
+		note := ""
+		switch err := item.CheckDownloadable(); {
+		case errors.Is(err, domain.ErrAttachmentQuarantined):
+			note, name = noteQuarantined, ""
+		case errors.Is(err, domain.ErrAttachmentMissing):
+			note, name = noteMissing, ""
+		case errors.Is(err, domain.ErrAttachmentTooLargeToScan):
+			note, name = noteTooLarge, ""
+		case err != nil:
+			note, name = noteNotScanned, ""
+		}
+		if note == "" {
+			err := copyBlob(ctx, zw, files, name, item.Attachment)
+			if errors.Is(err, storage.ErrNotFound) {
+				note, name = noteMissing, ""
+			} else if err != nil {
+				return fmt.Errorf("add %s: %w", item.StorageKey, err)
+			}
+		}
+
+		rows = append(rows, []string{
+			item.DefectID,
+			spreadsheetSafe(item.DefectTitle),
+			spreadsheetSafe(name),
+			spreadsheetSafe(item.Filename),
+			spreadsheetSafe(item.UploaderName),
+			item.UploadedAt.UTC().Format(time.RFC3339),
+			strconv.FormatInt(item.SizeBytes, 10),
+			item.Checksum,
+			note,
+		})
+	}
+
+	manifest, err := zw.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	// The BOM makes spreadsheet applications read Cyrillic text as UTF-8.
+	if _, err := io.WriteString(manifest, "\ufeff"); err != nil {
+		return err
+	}
+	if err := csv.NewWriter(manifest).WriteAll(rows); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// spreadsheetSafe keeps user-authored manifest cells from being evaluated as
+// formulas when the manifest is opened in a spreadsheet application.
+func spreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func copyBlob(ctx context.Context, zw *zip.Writer, files storage.Provider, name string, att domain.Attachment) error {
+	object, err := files.Open(ctx, att.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: compressionMethod(att.ContentType), Modified: att.UploadedAt})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, object)
+	return err
+}
+
+// compressionMethod stores already compressed media as is: deflating photos
+// and video costs CPU and saves nothing.
+func compressionMethod(contentType string) uint16 {
+	if strings.HasPrefix(contentType, "image/") && contentType != "image/tiff" && contentType != "image/vnd.dwg" {
+		return zip.Store
+	}
+	if strings.HasPrefix(contentType, "video/") {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// defectFolder names the folder after the defect title; the short id keeps
+// defects with equal titles apart and lets the folder be matched to the system.
+func defectFolder(item domain.ExportedAttachment) string {
+	id := item.DefectID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	title := []rune(safeName(item.DefectTitle, "defect"))
+	if len(title) > 80 {
+		title = title[:80]
+	}
+	return strings.TrimSpace(string(title)) + " (" + id + ")"
+}
+
+// safeName turns user input into a single path element valid on common file systems.
+func safeName(name, fallback string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// uniqueName appends " (2)", " (3)"... before the extension until the name is unused.
+func uniqueName(used map[string]bool, name string) string {
+	candidate := name
+	ext := path.Ext(name)
+	if strings.Contains(ext, "/") || strings.Contains(ext, " (") {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails to users.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Log writes messages to the application log instead of sending them. It is
+// the default for development, where links from the emails are copied from
+// the log.
+type Log struct {
+	log *zap.Logger
+}
+
+func NewLog(log *zap.Logger) *Log {
+	return &Log{log: log}
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	l.log.Info("email not sent, MAIL_DRIVER=log",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+	return nil
+}
@@ -0,0 +1,68 @@
+package photometa
+
+import (
+	"io"
+	"math"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+
+	"defect-tracker/internal/domain"
+)
+
+// Supports reports whether EXIF can be read from the content type.
+func Supports(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/tiff"
+}
+
+// Extract reads EXIF from a JPEG or TIFF stream. Photos without EXIF or with
+// a broken EXIF segment yield empty metadata.
+func Extract(r io.Reader) domain.PhotoMetadata {
+	x, _ := exif.Decode(r)
+	if x == nil {
+		return domain.PhotoMetadata{}
+	}
+
+	var meta domain.PhotoMetadata
+	if taken, err := x.DateTime(); err == nil && !taken.IsZero() {
+		meta.TakenAt = &taken
+	}
+	if lat, lon, err := x.LatLong(); err == nil && validCoordinates(lat, lon) {
+		meta.Latitude, meta.Longitude = &lat, &lon
+	}
+	meta.CameraModel = cameraModel(x)
+	return meta
+}
+
+// validCoordinates drops NaN and the 0,0 placeholder written by some cameras without a GPS fix.
+func validCoordinates(lat, lon float64) bool {
+	if math.IsNaN(lat) || math.IsNaN(lon) || (lat == 0 && lon == 0) {
+		return false
+	}
+	return math.Abs(lat) <= 90 && math.Abs(lon) <= 180
+}
+
+// cameraModel combines Make and Model, skipping the make when the model already contains it.
+func cameraModel(x *exif.Exif) string {
+	model := tagString(x, exif.Model)
+	vendor := tagString(x, exif.Make)
+	if vendor == "" || strings.Contains(strings.ToLower(model), strings.ToLower(vendor)) {
+		return model
+	}
+	if model == "" {
+		return vendor
+	}
+	return vendor + " " + model
+}
+
+func tagString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(value, "\x00"))
+}
@@ -0,0 +1,137 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of INSTREAM chunks; clamd accepts any size up to
+// its StreamMaxLength.
+const clamdChunkSize = 64 << 10
+
+// Clamd scans files with a ClamAV daemon over its TCP protocol, streaming the
+// content with the INSTREAM command. Files larger than the daemon's
+// StreamMaxLength are reported as an error.
+type Clamd struct {
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamd returns a scanner talking to clamd at addr ("host:port"). timeout
+// bounds one scan, including the upload of the content.
+func NewClamd(addr string, timeout time.Duration) *Clamd {
+	return &Clamd{addr: addr, timeout: timeout}
+}
+
+// Ping checks that the daemon is reachable.
+func (c *Clamd) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: unexpected reply to PING: %q", reply)
+	}
+	return nil
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+	reply, err := c.command(ctx, "INSTREAM", r)
+	if err != nil {
+		return Verdict{}, err
+	}
+	return parseReply(reply)
+}
+
+// command sends a null-terminated command, then the stream in length-prefixed
+// chunks if body is set, and returns the reply without the terminator.
+func (c *Clamd) command(ctx context.Context, name string, body io.Reader) (string, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return "", fmt.Errorf("clamd: %w: %w", ErrUnavailable, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if _, err := io.WriteString(conn, "z"+name+"\x00"); err != nil {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+	var sendErr error
+	if body != nil {
+		sendErr = sendStream(conn, body)
+	}
+
+	// clamd answers and hangs up when the stream exceeds its limit, so the
+	// reply is read even if sending failed.
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && (reply == "" || !errors.Is(err, io.EOF)) {
+		if sendErr != nil {
+			return "", fmt.Errorf("clamd: %w", sendErr)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("clamd: %w", ctxErr)
+		}
+		return "", fmt.Errorf("clamd: read reply: %w", err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+func sendStream(w io.Writer, r io.Reader) error {
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// A zero-length chunk ends the stream.
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseReply interprets "stream: OK", "stream: <signature> FOUND" and
+// "<reason> ERROR". The reply to a stream above StreamMaxLength is reported
+// as ErrTooLarge.
+func parseReply(reply string) (Verdict, error) {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return Verdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	case strings.HasSuffix(result, " ERROR"):
+		reason := strings.TrimSuffix(result, " ERROR")
+		if strings.Contains(reason, "size limit exceeded") {
+			return Verdict{}, fmt.Errorf("clamd: %s: %w", reason, ErrTooLarge)
+		}
+		return Verdict{}, fmt.Errorf("clamd: %s", reason)
+	default:
+		return Verdict{}, fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd accepts one connection, reads an INSTREAM command and its chunks
+// and answers with reply. With hangUp set it closes the connection after the
+// first chunk instead of answering. The received content is sent to got.
+func fakeClamd(t *testing.T, reply string, hangUp bool) (string, <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	got := make(chan []byte, 1)
+	go func() {
+		defer close(got)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		cmd, err := r.ReadString(0)
+		if err != nil || cmd != "zINSTREAM\x00" {
+			t.Errorf("unexpected command %q: %v", cmd, err)
+			return
+		}
+		var content bytes.Buffer
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				t.Errorf("read chunk size: %v", err)
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, r, int64(size)); err != nil {
+				t.Errorf("read chunk: %v", err)
+				return
+			}
+			if hangUp {
+				got <- content.Bytes()
+				return
+			}
+		}
+		got <- content.Bytes()
+		_, _ = io.WriteString(conn, reply+"\x00")
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestClamdScan(t *testing.T) {
+	content := bytes.Repeat([]byte("defect photo "), clamdChunkSize/5)
+	tests := []struct {
+		name         string
+		reply        string
+		want         Verdict
+		wantErr      string
+		wantTooLarge bool
+	}{
+		{name: "clean", reply: "stream: OK", want: Verdict{}},
+		{name: "infected", reply: "stream: Eicar-Test-Signature FOUND", want: Verdict{Infected: true, Signature: "Eicar-Test-Signature"}},
+		{name: "daemon error", reply: "Can't allocate memory ERROR", wantErr: "Can't allocate memory"},
+		{name: "size limit", reply: "INSTREAM size limit exceeded. ERROR", wantErr: "INSTREAM size limit exceeded.", wantTooLarge: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, got := fakeClamd(t, tt.reply, false)
+			verdict, err := NewClamd(addr, 5*time.Second).Scan(context.Background(), bytes.NewReader(content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Scan() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if errors.Is(err, ErrTooLarge) != tt.wantTooLarge {
+				t.Errorf("Scan() error = %v, ErrTooLarge expected: %v", err, tt.wantTooLarge)
+			}
+			if verdict != tt.want {
+				t.Errorf("Scan() = %+v, want %+v", verdict, tt.want)
+			}
+			if received := <-got; !bytes.Equal(received, content) {
+				t.Errorf("daemon received %d bytes, want %d", len(received), len(content))
+			}
+		})
+	}
+}
+
+func TestClamdScanHangUpMidStream(t *testing.T) {
+	addr, got := fakeClamd(t, "", true)
+	content := bytes.Repeat([]byte{1}, 4*clamdChunkSize)
+	_, err := NewClamd(addr, 5*time.Second).Scan(context.Background(), bytes.NewReader(content))
+	if err == nil {
+		t.Fatal("Scan() succeeded after the daemon hung up")
+	}
+	if errors.Is(err, ErrUnavailable) {
+		t.Errorf("Scan() error = %v, a failed scan must not be reported as an unreachable daemon", err)
+	}
+	<-got
+}
+
+func TestClamdScanUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = NewClamd(addr, time.Second).Scan(context.Background(), strings.NewReader("x"))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Scan() error = %v, want ErrUnavailable", err)
+	}
+}
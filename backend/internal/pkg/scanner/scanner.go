@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrUnavailable is wrapped by scan errors when the scanner could not be
+// reached at all, as opposed to failing on a particular file.
+var ErrUnavailable = errors.New("scanner unavailable")
+
+// ErrTooLarge is wrapped by scan errors when the file exceeds the size the
+// scanner accepts; scanning it again will not succeed.
+var ErrTooLarge = errors.New("file exceeds the scanner size limit")
+
+// Verdict is the outcome of a scan. Signature names the malware found.
+type Verdict struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner checks file content for malware. An error means the content could
+// not be scanned, not that it is infected.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Verdict, error)
+}
+
+// Noop accepts every file. It is used when no scanner is configured.
+type Noop struct{}
+
+func (Noop) Scan(context.Context, io.Reader) (Verdict, error) {
+	return Verdict{}, nil
+}
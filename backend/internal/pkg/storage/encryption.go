@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encrypted blobs start with a header naming the master key and holding the
+// per-file data key wrapped by it, followed by the content sealed with
+// AES-256-GCM in segments. Segments can be decrypted independently, so
+// encrypted blobs still support seeking and HTTP Range requests.
+const (
+	encMagic       = "DTENC1\x00\n"
+	segmentSize    = 64 << 10
+	dataKeySize    = 32
+	gcmNonceSize   = 12
+	gcmTagSize     = 16
+	sealedSegment  = segmentSize + gcmTagSize
+	maxKeyIDLength = 64
+)
+
+var (
+	// ErrUnknownKey is returned for blobs encrypted with a master key that is
+	// not configured anymore.
+	ErrUnknownKey = errors.New("blob is encrypted with an unknown master key")
+	// ErrCorrupted is returned when an encrypted blob fails authentication.
+	ErrCorrupted = errors.New("encrypted blob is corrupted or truncated")
+)
+
+// Keyring holds the master keys. New blobs are encrypted under the active
+// key; older keys are kept to read blobs written before a rotation.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// ParseKeyring reads keys given as "id:base64-key" with 32-byte keys. The
+// first key is the active one.
+func ParseKeyring(specs []string) (*Keyring, error) {
+	ring := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(spec, ":")
+		if !ok || id == "" || len(id) > maxKeyIDLength {
+			return nil, fmt.Errorf("master key must be written as id:base64-key with an id of up to %d characters", maxKeyIDLength)
+		}
+		if _, exists := ring.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate master key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("master key %q must be %d bytes encoded in base64", id, dataKeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[id] = aead
+		if ring.active == "" {
+			ring.active = id
+		}
+	}
+	if ring.active == "" {
+		return nil, errors.New("no master keys given")
+	}
+	return ring, nil
+}
+
+// ActiveKeyID returns the id of the key new blobs are encrypted with.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newHeader generates a data key and returns it with the header that stores
+// it wrapped by the active master key.
+func (k *Keyring) newHeader() (cipher.AEAD, []byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	// The key id is authenticated so a wrapped key cannot be moved under another id.
+	wrapped := k.keys[k.active].Seal(nonce, nonce, dataKey, []byte(k.active))
+
+	header := make([]byte, 0, len(encMagic)+2+len(k.active)+len(wrapped))
+	header = append(header, encMagic...)
+	header = append(header, byte(len(k.active)))
+	header = append(header, k.active...)
+	header = append(header, byte(len(wrapped)))
+	header = append(header, wrapped...)
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, header, nil
+}
+
+// readHeader parses the header at the start of r. It returns a nil AEAD and
+// no error for blobs that are not encrypted, e.g. written before encryption
+// was enabled. keyID is the master key the blob is encrypted with.
+func (k *Keyring) readHeader(r io.Reader) (aead cipher.AEAD, keyID string, length int64, err error) {
+	magic := make([]byte, len(encMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != encMagic {
+		return nil, "", 0, nil
+	}
+	if k == nil {
+		return nil, "", 0, ErrUnknownKey
+	}
+
+	field := func() ([]byte, error) {
+		var size [1]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, ErrCorrupted
+		}
+		value := make([]byte, size[0])
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, ErrCorrupted
+		}
+		return value, nil
+	}
+	id, err := field()
+	if err != nil {
+		return nil, "", 0, err
+	}
+	wrapped, err := field()
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	master, ok := k.keys[string(id)]
+	if !ok {
+		return nil, string(id), 0, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	if len(wrapped) < gcmNonceSize {
+		return nil, "", 0, ErrCorrupted
+	}
+	dataKey, err := master.Open(nil, wrapped[:gcmNonceSize], wrapped[gcmNonceSize:], id)
+	if err != nil {
+		return nil, "", 0, ErrCorrupted
+	}
+	aead, err = newAEAD(dataKey)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	return aead, string(id), int64(len(encMagic) + 2 + len(id) + len(wrapped)), nil
+}
+
+// segmentNonce derives the nonce from the segment index. Data keys are never
+// reused, so the index is unique per key. The final segment is marked to
+// detect truncation at a segment boundary.
+func segmentNonce(index int64, final bool) []byte {
+	nonce := make([]byte, gcmNonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], uint64(index))
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptStream seals r segment by segment into w and returns the plaintext
+// length. One segment is read ahead to know which one is final; empty content
+// still gets a final empty segment.
+func encryptStream(w io.Writer, r io.Reader, aead cipher.AEAD) (int64, error) {
+	current := make([]byte, segmentSize)
+	next := make([]byte, segmentSize)
+	sealed := make([]byte, 0, sealedSegment)
+
+	n, err := readSegment(r, current)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for index := int64(0); ; index++ {
+		final := n < segmentSize
+		m := 0
+		if !final {
+			if m, err = readSegment(r, next); err != nil {
+				return total, err
+			}
+			final = m == 0
+		}
+		sealed = aead.Seal(sealed[:0], segmentNonce(index, final), current[:n], nil)
+		if _, err := w.Write(sealed); err != nil {
+			return total, err
+		}
+		total += int64(n)
+		if final {
+			return total, nil
+		}
+		current, next, n = next, current, m
+	}
+}
+
+func readSegment(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, nil
+	}
+	return n, err
+}
+
+// decryptingReader serves the plaintext of an encrypted blob, decrypting the
+// segment under the read position on demand.
+type decryptingReader struct {
+	src      io.ReaderAt
+	closer   io.Closer
+	aead     cipher.AEAD
+	offset   int64 // where the first segment starts
+	body     int64 // length of the sealed segments
+	segments int64
+	size     int64 // plaintext length
+	pos      int64
+
+	loaded int64 // index of the segment in plain, -1 if none
+	plain  []byte
+	sealed []byte
+}
+
+func newDecryptingReader(src io.ReaderAt, closer io.Closer, aead cipher.AEAD, offset, fileSize int64) (*decryptingReader, error) {
+	body := fileSize - offset
+	segments := (body + sealedSegment - 1) / sealedSegment
+	last := body - (segments-1)*sealedSegment
+	if segments == 0 || last < gcmTagSize {
+		return nil, ErrCorrupted
+	}
+	return &decryptingReader{
+		src:      src,
+		closer:   closer,
+		aead:     aead,
+		offset:   offset,
+		body:     body,
+		segments: segments,
+		size:     body - segments*gcmTagSize,
+		loaded:   -1,
+		sealed:   make([]byte, sealedSegment),
+	}, nil
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+	index := d.pos / segmentSize
+	if err := d.load(index); err != nil {
+		return 0, err
+	}
+	n := copy(p, d.plain[d.pos-index*segmentSize:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+func (d *decryptingReader) load(index int64) error {
+	if d.loaded == index {
+		return nil
+	}
+	start := index * sealedSegment
+	length := min(int64(sealedSegment), d.body-start)
+	sealed := d.sealed[:length]
+	if _, err := d.src.ReadAt(sealed, d.offset+start); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	plain, err := d.aead.Open(d.plain[:0], segmentNonce(index, index == d.segments-1), sealed, nil)
+	if err != nil {
+		d.loaded = -1
+		return ErrCorrupted
+	}
+	d.plain, d.loaded = plain, index
+	return nil
+}
+
+func (d *decryptingReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.pos
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	d.pos = offset
+	return offset, nil
+}
+
+func (d *decryptingReader) Close() error {
+	return d.closer.Close()
+}
@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// masterKey returns a keyring spec with a random key under id.
+func masterKey(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+func encryptedLocal(t *testing.T, root string, specs ...string) *Local {
+	t.Helper()
+	keys, err := ParseKeyring(specs)
+	if err != nil {
+		t.Fatalf("ParseKeyring() error = %v", err)
+	}
+	local, err := NewEncryptedLocal(root, keys)
+	if err != nil {
+		t.Fatalf("NewEncryptedLocal() error = %v", err)
+	}
+	return local
+}
+
+func randomContent(t *testing.T, size int) []byte {
+	t.Helper()
+	content := make([]byte, size)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func put(t *testing.T, local *Local, key string, content []byte) {
+	t.Helper()
+	if _, err := local.Put(context.Background(), key, bytes.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+}
+
+func readBlob(local *Local, key string) ([]byte, error) {
+	object, err := local.Open(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return io.ReadAll(object)
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	sizes := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"segment minus one", segmentSize - 1},
+		{"one segment", segmentSize},
+		{"segment plus one", segmentSize + 1},
+		{"three segments", 3 * segmentSize},
+		{"three segments and a tail", 3*segmentSize + 17},
+	}
+	local := encryptedLocal(t, t.TempDir(), masterKey(t, "k1"))
+	for _, tt := range sizes {
+		t.Run(tt.name, func(t *testing.T) {
+			content := randomContent(t, tt.size)
+			put(t, local, tt.name, content)
+
+			raw, err := os.ReadFile(local.PathFor(tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.size >= 16 && bytes.Contains(raw, content) {
+				t.Error("the file holds the content in plain")
+			}
+
+			object, err := local.Open(context.Background(), tt.name)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer object.Close()
+			if object.Size != int64(tt.size) {
+				t.Errorf("Size = %d, want %d", object.Size, tt.size)
+			}
+			got, err := io.ReadAll(object)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("read %d bytes that differ from the %d written", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestEncryptedSeek(t *testing.T) {
+	local := encryptedLocal(t, t.TempDir(), masterKey(t, "k1"))
+	content := randomContent(t, 3*segmentSize+100)
+	put(t, local, "blob", content)
+	size := int64(len(content))
+
+	object, err := local.Open(context.Background(), "blob")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer object.Close()
+
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		want   int64
+		length int
+	}{
+		{"start", 0, io.SeekStart, 0, 10},
+		{"inside the first segment", 1000, io.SeekStart, 1000, 10},
+		{"across a segment boundary", segmentSize - 5, io.SeekStart, segmentSize - 5, 10},
+		{"segment start", 2 * segmentSize, io.SeekStart, 2 * segmentSize, segmentSize + 50},
+		{"back to the first segment", -(3*segmentSize + 50) + 3, io.SeekCurrent, 3, 100},
+		{"last byte", -1, io.SeekEnd, size - 1, 1},
+		{"end", 0, io.SeekEnd, size, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := object.Seek(tt.offset, tt.whence)
+			if err != nil {
+				t.Fatalf("Seek() error = %v", err)
+			}
+			if pos != tt.want {
+				t.Fatalf("Seek() = %d, want %d", pos, tt.want)
+			}
+			got := make([]byte, tt.length)
+			if _, err := io.ReadFull(object, got); err != nil {
+				t.Fatalf("ReadFull() error = %v", err)
+			}
+			if !bytes.Equal(got, content[pos:pos+int64(tt.length)]) {
+				t.Errorf("content at %d differs", pos)
+			}
+			if tt.length == 0 {
+				if n, err := object.Read(make([]byte, 1)); n != 0 || !errors.Is(err, io.EOF) {
+					t.Errorf("Read() at the end = %d, %v, want io.EOF", n, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptionDetectsTampering(t *testing.T) {
+	// Two full segments and a partial final one.
+	content := randomContent(t, 2*segmentSize+10)
+	tests := []struct {
+		name   string
+		tamper func(body []byte) []byte
+	}{
+		{"final segment dropped", func(body []byte) []byte {
+			return body[:2*sealedSegment]
+		}},
+		{"truncated inside a segment", func(body []byte) []byte {
+			return body[:sealedSegment+100]
+		}},
+		{"segments reordered", func(body []byte) []byte {
+			out := append([]byte{}, body[sealedSegment:2*sealedSegment]...)
+			out = append(out, body[:sealedSegment]...)
+			return append(out, body[2*sealedSegment:]...)
+		}},
+		{"byte flipped", func(body []byte) []byte {
+			body[segmentSize+3] ^= 1
+			return body
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := encryptedLocal(t, t.TempDir(), masterKey(t, "k1"))
+			put(t, local, "blob", content)
+
+			path := local.PathFor("blob")
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			headerSize := len(raw) - (2*sealedSegment + 10 + gcmTagSize)
+			header := raw[:headerSize]
+			tampered := append(append([]byte{}, header...), tt.tamper(raw[headerSize:])...)
+			if err := os.WriteFile(path, tampered, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readBlob(local, "blob")
+			if !errors.Is(err, ErrCorrupted) {
+				t.Fatalf("read error = %v with %d bytes, want ErrCorrupted", err, len(got))
+			}
+		})
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	root := t.TempDir()
+	oldKey, newKey := masterKey(t, "old"), masterKey(t, "new")
+	content := randomContent(t, segmentSize+123)
+
+	put(t, encryptedLocal(t, root, oldKey), "blob", content)
+	plain, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, plain, "plain", content)
+
+	rotated := encryptedLocal(t, root, newKey, oldKey)
+	if got, err := readBlob(rotated, "blob"); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("reading a blob under the rotated-out key: %v", err)
+	}
+
+	reencrypt := []struct {
+		key    string
+		dryRun bool
+		want   string
+	}{
+		{"blob", true, ReencryptReencrypted},
+		{"blob", false, ReencryptReencrypted},
+		{"blob", false, ReencryptCurrent},
+		{"plain", false, ReencryptEncrypted},
+		{"plain", false, ReencryptCurrent},
+	}
+	for _, tt := range reencrypt {
+		status, err := rotated.Reencrypt(context.Background(), tt.key, tt.dryRun)
+		if err != nil {
+			t.Fatalf("Reencrypt(%s, dryRun=%v) error = %v", tt.key, tt.dryRun, err)
+		}
+		if status != tt.want {
+			t.Errorf("Reencrypt(%s, dryRun=%v) = %q, want %q", tt.key, tt.dryRun, status, tt.want)
+		}
+	}
+
+	// Once re-encrypted, blobs are readable without the old key.
+	current := encryptedLocal(t, root, newKey)
+	for _, key := range []string{"blob", "plain"} {
+		if got, err := readBlob(current, key); err != nil || !bytes.Equal(got, content) {
+			t.Errorf("reading %s with the new key only: %v", key, err)
+		}
+	}
+}
+
+func TestEncryptionUnknownKey(t *testing.T) {
+	root := t.TempDir()
+	put(t, encryptedLocal(t, root, masterKey(t, "k1")), "blob", []byte("site photo"))
+
+	if _, err := readBlob(encryptedLocal(t, root, masterKey(t, "k2")), "blob"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("read with another key: error = %v, want ErrUnknownKey", err)
+	}
+	// A key with the same id but different bytes cannot unwrap the data key.
+	if _, err := readBlob(encryptedLocal(t, root, masterKey(t, "k1")), "blob"); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("read with a different key under the same id: error = %v, want ErrCorrupted", err)
+	}
+	plain, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readBlob(plain, "blob"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("read without keys: error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	valid := masterKey(t, "k1")
+	tests := []struct {
+		name    string
+		specs   []string
+		active  string
+		wantErr bool
+	}{
+		{"first key is active", []string{valid, masterKey(t, "k2")}, "k1", false},
+		{"blank entries skipped", []string{" ", valid}, "k1", false},
+		{"no keys", []string{""}, "", true},
+		{"missing id", []string{":" + base64.StdEncoding.EncodeToString(make([]byte, dataKeySize))}, "", true},
+		{"short key", []string{"k1:" + base64.StdEncoding.EncodeToString(make([]byte, 16))}, "", true},
+		{"not base64", []string{"k1:***"}, "", true},
+		{"duplicate id", []string{valid, valid}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := ParseKeyring(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && ring.ActiveKeyID() != tt.active {
+				t.Errorf("ActiveKeyID() = %q, want %q", ring.ActiveKeyID(), tt.active)
+			}
+		})
+	}
+}
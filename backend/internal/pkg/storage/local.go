@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local keeps blobs as files under a root directory, optionally encrypted.
+type Local struct {
+	root string
+	keys *Keyring
+}
+
+func NewLocal(root string) (*Local, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+	return &Local{root: abs}, nil
+}
+
+// NewEncryptedLocal is NewLocal with files encrypted under the active key of
+// keys. Files written before encryption was enabled are still read as is
+// until Reencrypt converts them.
+func NewEncryptedLocal(root string, keys *Keyring) (*Local, error) {
+	local, err := NewLocal(root)
+	if err != nil {
+		return nil, err
+	}
+	local.keys = keys
+	return local, nil
+}
+
+func (l *Local) Save(ctx context.Context, r io.Reader, filename string, size int64, contentType string) (Saved, error) {
+	return save(r, filename, func(key string, r io.Reader) (int64, error) {
+		return l.Put(ctx, key, r, size, contentType)
+	})
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (int64, error) {
+	fullPath := l.PathFor(key)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return 0, err
+	}
+	return l.writeFile(fullPath, r)
+}
+
+// writeFile writes into a temporary file first so readers never see a partial
+// file, encrypting the content when keys are configured. It returns the
+// number of bytes read from r.
+func (l *Local) writeFile(fullPath string, r io.Reader) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	var written int64
+	if l.keys != nil {
+		written, err = l.encryptTo(file, r)
+	} else {
+		written, err = io.Copy(file, r)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), fullPath)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return 0, err
+	}
+	return written, nil
+}
+
+func (l *Local) encryptTo(w io.Writer, r io.Reader) (int64, error) {
+	aead, header, err := l.keys.newHeader()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+	return encryptStream(w, r, aead)
+}
+
+// Presign is not supported by the local driver: files are served through the API.
+func (l *Local) Presign(ctx context.Context, key string) (string, error) {
+	return "", nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (*Object, error) {
+	object, _, err := l.openFile(l.PathFor(key))
+	return object, err
+}
+
+// openFile opens a plain or encrypted file and returns the master key id of
+// an encrypted one. Size is the length of the content, not of the file.
+func (l *Local) openFile(fullPath string) (*Object, string, error) {
+	file, err := os.Open(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+
+	object := &Object{
+		ReadSeekCloser: file,
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+		ETag:           fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}
+	aead, keyID, headerSize, err := l.keys.readHeader(file)
+	if err == nil && aead == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err == nil && aead != nil {
+		var reader *decryptingReader
+		reader, err = newDecryptingReader(file, file, aead, headerSize, info.Size())
+		if err == nil {
+			object.ReadSeekCloser, object.Size = reader, reader.size
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	return object, keyID, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	err := os.Remove(l.PathFor(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks the root directory. Hidden entries, such as unfinished uploads, are skipped.
+func (l *Local) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return filepath.WalkDir(l.root, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && fullPath != l.root {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, fullPath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	})
+}
+
+// Outcomes of Reencrypt.
+const (
+	ReencryptEncrypted   = "encrypted"
+	ReencryptReencrypted = "reencrypted"
+	ReencryptCurrent     = "current"
+)
+
+// Reencrypt rewrites the blob under the active master key with a fresh data
+// key: plain files are encrypted and files under an older master key are
+// re-encrypted. It returns what was done, or would be done with dryRun. The
+// modification time is kept so the orphaned blob collector is not confused.
+func (l *Local) Reencrypt(ctx context.Context, key string, dryRun bool) (string, error) {
+	if l.keys == nil {
+		return "", errors.New("encryption is not configured")
+	}
+	fullPath := l.PathFor(key)
+	object, keyID, err := l.openFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+	defer object.Close()
+
+	status := ReencryptReencrypted
+	switch keyID {
+	case l.keys.ActiveKeyID():
+		return ReencryptCurrent, nil
+	case "":
+		status = ReencryptEncrypted
+	}
+	if dryRun {
+		return status, nil
+	}
+
+	if _, err := l.writeFile(fullPath, object); err != nil {
+		return "", err
+	}
+	if err := os.Chtimes(fullPath, time.Time{}, object.ModTime); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// multipartDir is hidden so that List never reports unfinished uploads.
+const multipartDir = ".multipart"
+
+func (l *Local) CreateMultipart(ctx context.Context, filename string) (string, string, error) {
+	key, err := newKey(filename)
+	if err != nil {
+		return "", "", err
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	uploadID := hex.EncodeToString(buf)
+	dir, err := l.partsDir(uploadID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+	return key, uploadID, nil
+}
+
+func (l *Local) UploadPart(ctx context.Context, key, uploadID string, number int, r io.Reader, size int64) (Part, error) {
+	dir, err := l.partsDir(uploadID)
+	if err != nil {
+		return Part{}, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return Part{}, ErrNotFound
+	}
+	// Parts are encrypted like blobs: an unfinished upload is on the disk too.
+	written, err := l.writeFile(filepath.Join(dir, strconv.Itoa(number)), r)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Number: number, Size: written}, nil
+}
+
+func (l *Local) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	dir, err := l.partsDir(uploadID)
+	if err != nil {
+		return err
+	}
+	readers := make([]io.Reader, 0, len(parts))
+	var total int64
+	for _, part := range parts {
+		file, _, err := l.openFile(filepath.Join(dir, strconv.Itoa(part.Number)))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		readers = append(readers, file)
+		total += part.Size
+	}
+	if _, err := l.Put(ctx, key, io.MultiReader(readers...), total, ""); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (l *Local) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	dir, err := l.partsDir(uploadID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// partsDir keeps parts of an upload. The id is checked to be one generated by
+// CreateMultipart so that it can never point outside the parts directory.
+func (l *Local) partsDir(uploadID string) (string, error) {
+	if _, err := hex.DecodeString(uploadID); err != nil || uploadID == "" {
+		return "", ErrNotFound
+	}
+	return filepath.Join(l.root, multipartDir, uploadID), nil
+}
+
+// PathFor maps a key to a file path, never escaping the root directory.
+func (l *Local) PathFor(key string) string {
+	clean := filepath.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	return filepath.Join(l.root, filepath.FromSlash(clean))
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Config struct {
+	Endpoint   string
+	AccessKey  string
+	SecretKey  string
+	Bucket     string
+	Region     string
+	UseSSL     bool
+	PresignTTL time.Duration
+}
+
+// S3 keeps blobs in an S3-compatible bucket (AWS, MinIO, Yandex Object Storage).
+type S3 struct {
+	client     *minio.Client
+	bucket     string
+	presignTTL time.Duration
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	// minio expects host:port; accept endpoints written as URLs too.
+	endpoint, secure := cfg.Endpoint, cfg.UseSSL
+	if u, err := url.Parse(cfg.Endpoint); err == nil && u.Host != "" {
+		endpoint, secure = u.Host, secure || u.Scheme == "https"
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: secure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("init s3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("check bucket: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("create bucket: %w", err)
+		}
+	}
+
+	ttl := cfg.PresignTTL
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	return &S3{client: client, bucket: cfg.Bucket, presignTTL: ttl}, nil
+}
+
+func (s *S3) Save(ctx context.Context, r io.Reader, filename string, size int64, contentType string) (Saved, error) {
+	return save(r, filename, func(key string, r io.Reader) (int64, error) {
+		return s.Put(ctx, key, r, size, contentType)
+	})
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (int64, error) {
+	if size <= 0 {
+		size = -1
+	}
+	info, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+func (s *S3) Presign(ctx context.Context, key string) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.presignTTL, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Open stats the object first so that missing keys surface as ErrNotFound
+// before any response headers are written.
+func (s *S3) Open(ctx context.Context, key string) (*Object, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &Object{
+		ReadSeekCloser: obj,
+		Size:           info.Size,
+		ModTime:        info.LastModified,
+		ETag:           `"` + info.ETag + `"`,
+		ContentType:    info.ContentType,
+	}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	// Cancelling stops the listing goroutine when fn fails midway.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if err := fn(ObjectInfo{Key: object.Key, Size: object.Size, ModTime: object.LastModified}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3) CreateMultipart(ctx context.Context, filename string) (string, string, error) {
+	key, err := newKey(filename)
+	if err != nil {
+		return "", "", err
+	}
+	uploadID, err := s.core().NewMultipartUpload(ctx, s.bucket, key, minio.PutObjectOptions{})
+	if err != nil {
+		return "", "", err
+	}
+	return key, uploadID, nil
+}
+
+func (s *S3) UploadPart(ctx context.Context, key, uploadID string, number int, r io.Reader, size int64) (Part, error) {
+	part, err := s.core().PutObjectPart(ctx, s.bucket, key, uploadID, number, r, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Number: number, ETag: part.ETag, Size: part.Size}, nil
+}
+
+func (s *S3) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	completed := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err := s.core().CompleteMultipartUpload(ctx, s.bucket, key, uploadID, completed, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *S3) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	err := s.core().AbortMultipartUpload(ctx, s.bucket, key, uploadID)
+	if minio.ToErrorResponse(err).Code == "NoSuchUpload" {
+		return nil
+	}
+	return err
+}
+
+// core exposes the low-level multipart API of the client.
+func (s *S3) core() *minio.Core {
+	return &minio.Core{Client: s.client}
+}
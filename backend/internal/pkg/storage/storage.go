@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a key does not exist in the storage.
+var ErrNotFound = errors.New("storage object not found")
+
+// Provider stores attachment blobs under opaque keys.
+type Provider interface {
+	// Save writes the stream under a generated key, hashing it on the way.
+	Save(ctx context.Context, r io.Reader, filename string, size int64, contentType string) (Saved, error)
+	// Put writes the stream under the given key, replacing an existing blob.
+	// It is used for blobs derived from an original, e.g. thumbnails.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (int64, error)
+	// Presign returns a direct download URL or an empty string when the driver has none.
+	Presign(ctx context.Context, key string) (string, error)
+	// Open returns a seekable reader for the blob; the caller must close it.
+	Open(ctx context.Context, key string) (*Object, error)
+	// Delete removes the blob. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// List calls fn for every blob whose key starts with prefix.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+
+	// CreateMultipart starts a blob assembled from parts and returns its
+	// generated key and the upload id. Unfinished uploads are not listed.
+	CreateMultipart(ctx context.Context, filename string) (string, string, error)
+	// UploadPart stores part number (starting at 1) of the upload. Every part
+	// but the last must be at least MinPartSize bytes.
+	UploadPart(ctx context.Context, key, uploadID string, number int, r io.Reader, size int64) (Part, error)
+	// CompleteMultipart joins the parts in order into the blob under key.
+	CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error
+	// AbortMultipart discards the upload and its parts.
+	AbortMultipart(ctx context.Context, key, uploadID string) error
+}
+
+// MinPartSize is the smallest non-final part S3 accepts.
+const MinPartSize = 5 << 20
+
+// Part is a stored piece of a multipart upload.
+type Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// Saved describes a blob written by Save.
+type Saved struct {
+	Key  string
+	Size int64
+	// SHA256 is the hex-encoded digest of the written content.
+	SHA256 string
+}
+
+// ObjectInfo describes a stored blob without opening it.
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Object is an opened blob. Seeking allows serving HTTP Range requests.
+type Object struct {
+	io.ReadSeekCloser
+	Size        int64
+	ModTime     time.Time
+	ETag        string
+	ContentType string
+}
+
+// newKey builds a unique key like 2025/01/02/<random>.jpg keeping the original extension.
+func newKey(filename string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	ext := strings.ToLower(path.Ext(strings.ReplaceAll(filename, "\\", "/")))
+	if len(ext) > 10 || strings.ContainsAny(ext, " /") {
+		ext = ""
+	}
+	return time.Now().UTC().Format("2006/01/02") + "/" + hex.EncodeToString(buf) + ext, nil
+}
+
+// save generates a key and writes r through put, computing the SHA-256 of the
+// stream so the content is read only once.
+func save(r io.Reader, filename string, put func(key string, r io.Reader) (int64, error)) (Saved, error) {
+	key, err := newKey(filename)
+	if err != nil {
+		return Saved{}, err
+	}
+	digest := sha256.New()
+	written, err := put(key, io.TeeReader(r, digest))
+	if err != nil {
+		return Saved{}, err
+	}
+	return Saved{Key: key, Size: written, SHA256: hex.EncodeToString(digest.Sum(nil))}, nil
+}
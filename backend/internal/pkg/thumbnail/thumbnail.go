@@ -0,0 +1,113 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // register WebP decoder for site photos from Android tablets
+)
+
+// Size is a named bounding box a thumbnail is fitted into.
+type Size struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Sizes lists generated thumbnails from the smallest to the largest.
+var Sizes = []Size{
+	{Name: "small", Width: 160, Height: 160},
+	{Name: "medium", Width: 480, Height: 480},
+	{Name: "large", Width: 1280, Height: 1280},
+}
+
+// DefaultSize is used when a client does not ask for a particular size.
+const DefaultSize = "medium"
+
+// ContentType of every generated thumbnail.
+const ContentType = "image/jpeg"
+
+// MaxPixels caps the dimensions of images that are decoded. A small file can
+// declare a huge canvas, and decoding allocates it in full; 50 megapixels
+// still covers photos from current phone cameras.
+const MaxPixels = 50_000_000
+
+// ErrUnsupported is returned for content that cannot be decoded as an image
+// or that exceeds MaxPixels.
+var ErrUnsupported = errors.New("thumbnail: unsupported image format")
+
+// Supports reports whether thumbnails can be generated for the content type.
+func Supports(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp", "image/tiff":
+		return true
+	}
+	return false
+}
+
+// Key derives the storage key of a thumbnail from the original blob key.
+func Key(originalKey, size string) string {
+	return originalKey + ".thumb-" + size + ".jpg"
+}
+
+// OriginalKey reverses Key, reporting whether key belongs to a thumbnail.
+func OriginalKey(key string) (string, bool) {
+	for _, size := range Sizes {
+		if original, ok := strings.CutSuffix(key, ".thumb-"+size.Name+".jpg"); ok {
+			return original, true
+		}
+	}
+	return "", false
+}
+
+// FindSize returns the size by name.
+func FindSize(name string) (Size, bool) {
+	for _, size := range Sizes {
+		if size.Name == name {
+			return size, true
+		}
+	}
+	return Size{}, false
+}
+
+// Generate decodes the image, applies the EXIF orientation and renders a JPEG
+// for every entry of Sizes. Images smaller than a box are not upscaled.
+func Generate(r io.Reader) (map[string][]byte, error) {
+	// Check the declared dimensions before anything is allocated for pixels,
+	// then decode from the consumed header followed by the rest of the stream.
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, ErrUnsupported
+	}
+
+	img, err := imaging.Decode(io.MultiReader(&header, r), imaging.AutoOrientation(true))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(Sizes))
+	for _, size := range Sizes {
+		resized := img
+		bounds := img.Bounds()
+		if bounds.Dx() > size.Width || bounds.Dy() > size.Height {
+			resized = imaging.Fit(img, size.Width, size.Height, imaging.Lanczos)
+		}
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, resized, imaging.JPEG, imaging.JPEGQuality(80)); err != nil {
+			return nil, err
+		}
+		result[size.Name] = buf.Bytes()
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// untouchable fails the test when the image data past the header is read.
+type untouchable struct {
+	read bool
+}
+
+func (u *untouchable) Read([]byte) (int, error) {
+	u.read = true
+	return 0, errors.New("image data must not be read")
+}
+
+func TestGenerateRejectsHugeCanvas(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// Declare a 60000x60000 canvas in IHDR (after the 8-byte signature and the
+	// chunk length and type) and fix the chunk CRC.
+	file := buf.Bytes()
+	ihdr := file[12 : 12+4+13]
+	binary.BigEndian.PutUint32(ihdr[4:8], 60000)
+	binary.BigEndian.PutUint32(ihdr[8:12], 60000)
+	binary.BigEndian.PutUint32(file[12+4+13:], crc32.ChecksumIEEE(ihdr))
+
+	rest := &untouchable{}
+	_, err := Generate(io.MultiReader(bytes.NewReader(file[:12+4+13+4]), rest))
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Generate() error = %v, want ErrUnsupported", err)
+	}
+	if rest.read {
+		t.Error("Generate() read the pixel data of an oversized image")
+	}
+}
+
+// withOrientation inserts an EXIF APP1 segment with the orientation tag right
+// after the JPEG SOI marker.
+func withOrientation(jpegData []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	binary.Write(&tiff, binary.BigEndian, uint32(8))      // IFD offset
+	binary.Write(&tiff, binary.BigEndian, uint16(1))      // entry count
+	binary.Write(&tiff, binary.BigEndian, uint16(0x0112)) // Orientation
+	binary.Write(&tiff, binary.BigEndian, uint16(3))      // SHORT
+	binary.Write(&tiff, binary.BigEndian, uint32(1))      // count
+	binary.Write(&tiff, binary.BigEndian, orientation)
+	binary.Write(&tiff, binary.BigEndian, uint16(0)) // padding of the value
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // no next IFD
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestGenerateAppliesOrientationWithoutUpscaling(t *testing.T) {
+	// A 400x200 photo stored sideways: red on the left, blue on the right.
+	// Orientation 6 means it is displayed rotated 90° clockwise, 200x400 with
+	// red on top.
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 200 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	thumbs, err := Generate(bytes.NewReader(withOrientation(buf.Bytes(), 6)))
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	want := map[string]image.Point{
+		"small":  {X: 80, Y: 160},
+		"medium": {X: 200, Y: 400}, // fits the box, kept as is
+		"large":  {X: 200, Y: 400}, // never upscaled
+	}
+	for name, size := range want {
+		img, err := jpeg.Decode(bytes.NewReader(thumbs[name]))
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if got := img.Bounds().Size(); got != size {
+			t.Errorf("%s: size = %v, want %v", name, got, size)
+		}
+		top := color.RGBAModel.Convert(img.At(size.X/2, size.Y/8)).(color.RGBA)
+		bottom := color.RGBAModel.Convert(img.At(size.X/2, size.Y*7/8)).(color.RGBA)
+		if top.R < 200 || top.B > 60 || bottom.B < 200 || bottom.R > 60 {
+			t.Errorf("%s: top %v, bottom %v, want red on top and blue at the bottom", name, top, bottom)
+		}
+	}
+}
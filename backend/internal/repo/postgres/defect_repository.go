@@ -3,10 +3,16 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"defect-tracker/internal/domain"
@@ -21,7 +27,174 @@ func NewDefectRepository(pool *pgxpool.Pool) *DefectRepository {
 	return &DefectRepository{pool: pool}
 }
 
-func (r *DefectRepository) List(ctx context.Context, filter domain.DefectFilter) ([]domain.DefectListItem, error) {
+func (r *DefectRepository) db(ctx context.Context) querier {
+	return conn(ctx, r.pool)
+}
+
+// defectSorts maps sort keys to SQL expressions, the types used to cast cursor
+// values back and checks that a cursor value casts to that type.
+var defectSorts = map[string]struct {
+	expr  string
+	cast  string
+	valid func(string) bool
+}{
+	domain.SortCreatedAt: {expr: "d.created_at", cast: "timestamptz", valid: isTimestamp},
+	domain.SortUpdatedAt: {expr: "d.updated_at", cast: "timestamptz", valid: isTimestamp},
+	domain.SortDueDate:   {expr: "COALESCE(d.due_date, 'infinity'::date)", cast: "date", valid: isDate},
+	domain.SortPriority:  {expr: "d.priority", cast: "defect_priority", valid: isPriority},
+	domain.SortStatus: {
+		expr: `COALESCE((
+			SELECT ws.position FROM workflow_statuses ws
+			WHERE ws.code = d.status
+				AND ws.workflow_id = COALESCE(p.workflow_id, (SELECT id FROM workflows WHERE is_default))
+		), 0)`,
+		cast:  "int",
+		valid: isInt,
+	},
+	// %[1]s is replaced with the tsquery of the current search.
+	domain.SortRelevance: {
+		expr: `(ts_rank(d.search_vector, %[1]s) + 0.5 * COALESCE((
+			SELECT MAX(ts_rank(c.search_vector, %[1]s)) FROM defect_comments c
+			WHERE c.defect_id = d.id AND c.search_vector @@ %[1]s
+		), 0))::float8`,
+		cast:  "float8",
+		valid: isFloat,
+	},
+}
+
+// Cursor values are sort expressions rendered by PostgreSQL as text.
+func isTimestamp(value string) bool {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999-07:00:00"} {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isDate(value string) bool {
+	if value == "infinity" {
+		return true
+	}
+	_, err := time.Parse(time.DateOnly, value)
+	return err == nil
+}
+
+func isPriority(value string) bool {
+	switch value {
+	case "LOW", "MEDIUM", "HIGH", "CRITICAL":
+		return true
+	}
+	return false
+}
+
+func isInt(value string) bool {
+	_, err := strconv.ParseInt(value, 10, 32)
+	return err == nil
+}
+
+func isFloat(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// searchConfig is the text search configuration used for defects and comments.
+const searchConfig = "russian"
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// headline returns a ts_headline expression for a user-authored text column.
+// The text is HTML-escaped first, so the snippet is safe to render as HTML and
+// the only markup in it is the <mark> around matches.
+func headline(column, tsquery, options string) string {
+	escaped := column
+	for _, r := range [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&quot;"}, {"''", "&#39;"}} {
+		escaped = fmt.Sprintf("replace(%s, '%s', '%s')", escaped, r[0], r[1])
+	}
+	return fmt.Sprintf("ts_headline('%s', %s, %s, '%s')", searchConfig, escaped, tsquery, options)
+}
+
+func searchQuery(argPos int) string {
+	return fmt.Sprintf("websearch_to_tsquery('%s', $%d)", searchConfig, argPos)
+}
+
+// defectCursor points right after the last row of a page for a particular sort.
+type defectCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(cursor defectCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (defectCursor, error) {
+	var cursor defectCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, domain.ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, domain.ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(cursor.ID); err != nil {
+		return cursor, domain.ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// List returns one keyset-paginated page of defects and the total number of matches.
+func (r *DefectRepository) List(ctx context.Context, filter domain.DefectFilter) (domain.DefectPage, error) {
+	sort, ok := defectSorts[filter.Sort]
+	if !ok {
+		filter.Sort = domain.SortCreatedAt
+		sort = defectSorts[filter.Sort]
+	}
+
+	where, args := buildDefectFilter(filter)
+
+	var total int
+	if err := r.db(ctx).QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM defects d
+		LEFT JOIN projects p ON p.id = d.project_id
+		WHERE 1=1`+where, args...,
+	).Scan(&total); err != nil {
+		return domain.DefectPage{}, err
+	}
+
+	argPos := len(args) + 1
+	sortExpr := sort.expr
+	highlights := "NULL::text, NULL::text, NULL::text"
+	if filter.Query != "" {
+		tsquery := searchQuery(argPos)
+		args = append(args, filter.Query)
+		argPos++
+		if filter.Sort == domain.SortRelevance {
+			sortExpr = fmt.Sprintf(sort.expr, tsquery)
+		}
+		highlights = fmt.Sprintf(`
+			%[3]s,
+			CASE WHEN to_tsvector('%[1]s', COALESCE(d.description, '')) @@ %[2]s
+				THEN %[4]s ELSE '' END,
+			COALESCE((
+				SELECT %[5]s FROM defect_comments c
+				WHERE c.defect_id = d.id AND c.search_vector @@ %[2]s
+				ORDER BY ts_rank(c.search_vector, %[2]s) DESC
+				LIMIT 1
+			), '')`, searchConfig, tsquery,
+			headline("d.title", tsquery, headlineOptions+", HighlightAll=true"),
+			headline("d.description", tsquery, headlineOptions),
+			headline("c.body", tsquery, headlineOptions))
+	} else if filter.Sort == domain.SortRelevance {
+		filter.Sort = domain.SortCreatedAt
+		sort = defectSorts[filter.Sort]
+		sortExpr = sort.expr
+	}
+
 	queryBuilder := strings.Builder{}
 	queryBuilder.WriteString(`
 		SELECT
@@ -34,50 +207,53 @@ func (r *DefectRepository) List(ctx context.Context, filter domain.DefectFilter)
 			COALESCE(d.assignee_id::text, '') AS assignee_id,
 			COALESCE(u.full_name, '') AS assignee_name,
 			d.due_date,
-			d.updated_at
+			d.reopen_count,
+			d.updated_at,
+			(` + sortExpr + `)::text AS sort_value,
+			` + highlights + `
 		FROM defects d
 		LEFT JOIN projects p ON p.id = d.project_id
 		LEFT JOIN users u ON u.id = d.assignee_id
-		WHERE 1=1
-	`)
-
-	args := make([]any, 0, 4)
-	argPos := 1
-
-	if filter.Project != "" {
-		queryBuilder.WriteString(fmt.Sprintf(" AND d.project_id = $%d", argPos))
-		args = append(args, filter.Project)
-		argPos++
-	}
+		WHERE 1=1`)
+	queryBuilder.WriteString(where)
 
-	if filter.Status != "" {
-		queryBuilder.WriteString(fmt.Sprintf(" AND d.status = $%d", argPos))
-		args = append(args, filter.Status)
-		argPos++
+	direction, comparison := "ASC", ">"
+	if filter.SortDesc {
+		direction, comparison = "DESC", "<"
 	}
 
-	if filter.Priority != "" {
-		queryBuilder.WriteString(fmt.Sprintf(" AND d.priority = $%d", argPos))
-		args = append(args, filter.Priority)
-		argPos++
+	if filter.Cursor != "" {
+		cursor, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return domain.DefectPage{}, err
+		}
+		if cursor.Sort != filter.Sort || cursor.Desc != filter.SortDesc || !sort.valid(cursor.Value) {
+			return domain.DefectPage{}, domain.ErrInvalidCursor
+		}
+		queryBuilder.WriteString(fmt.Sprintf(" AND ((%s), d.id) %s ($%d::%s, $%d::uuid)", sortExpr, comparison, argPos, sort.cast, argPos+1))
+		args = append(args, cursor.Value, cursor.ID)
+		argPos += 2
 	}
 
-	queryBuilder.WriteString(" ORDER BY d.created_at DESC")
+	queryBuilder.WriteString(fmt.Sprintf(" ORDER BY (%s) %s, d.id %s", sortExpr, direction, direction))
 	queryBuilder.WriteString(fmt.Sprintf(" LIMIT $%d", argPos))
-	args = append(args, filter.Limit)
+	args = append(args, filter.Limit+1)
 
-	rows, err := r.pool.Query(ctx, queryBuilder.String(), args...)
+	rows, err := r.db(ctx).Query(ctx, queryBuilder.String(), args...)
 	if err != nil {
-		return nil, err
+		return domain.DefectPage{}, err
 	}
 	defer rows.Close()
 
-	items := make([]domain.DefectListItem, 0)
+	page := domain.DefectPage{Items: make([]domain.DefectListItem, 0), Total: total}
+	var lastSortValue string
 	for rows.Next() {
 		var (
-			item     domain.DefectListItem
-			dueDate  sql.NullTime
-			assignee sql.NullString
+			item      domain.DefectListItem
+			dueDate   sql.NullTime
+			assignee  sql.NullString
+			sortValue string
+			highlight struct{ title, description, comment sql.NullString }
 		)
 		if err := rows.Scan(
 			&item.ID,
@@ -89,9 +265,23 @@ func (r *DefectRepository) List(ctx context.Context, filter domain.DefectFilter)
 			&assignee,
 			&item.AssigneeName,
 			&dueDate,
+			&item.ReopenCount,
 			&item.UpdatedAt,
+			&sortValue,
+			&highlight.title,
+			&highlight.description,
+			&highlight.comment,
 		); err != nil {
-			return nil, err
+			return domain.DefectPage{}, err
+		}
+		if len(page.Items) == filter.Limit {
+			page.NextCursor = encodeCursor(defectCursor{
+				Sort:  filter.Sort,
+				Desc:  filter.SortDesc,
+				Value: lastSortValue,
+				ID:    page.Items[len(page.Items)-1].ID,
+			})
+			break
 		}
 		if dueDate.Valid {
 			item.DueDate = &dueDate.Time
@@ -99,10 +289,117 @@ func (r *DefectRepository) List(ctx context.Context, filter domain.DefectFilter)
 		if assignee.Valid {
 			item.AssigneeID = assignee.String
 		}
-		items = append(items, item)
+		if highlight.title.Valid {
+			item.Highlight = &domain.DefectHighlight{
+				Title:       highlight.title.String,
+				Description: highlight.description.String,
+				Comment:     highlight.comment.String,
+			}
+		}
+		lastSortValue = sortValue
+		page.Items = append(page.Items, item)
 	}
 
-	return items, rows.Err()
+	return page, rows.Err()
+}
+
+// defectIsFinal matches defects in a final status of their project workflow.
+// It expects defects aliased as d and projects as p.
+const defectIsFinal = `d.status IN (
+	SELECT ws.code FROM workflow_statuses ws
+	WHERE ws.is_final
+		AND ws.workflow_id = COALESCE(p.workflow_id, (SELECT id FROM workflows WHERE is_default))
+)`
+
+// buildDefectFilter renders filter conditions as " AND ..." clauses with positional args starting at $1.
+func buildDefectFilter(filter domain.DefectFilter) (string, []any) {
+	queryBuilder := strings.Builder{}
+	args := make([]any, 0, 8)
+	argPos := 1
+
+	add := func(format string, value any) {
+		queryBuilder.WriteString(fmt.Sprintf(format, argPos))
+		args = append(args, value)
+		argPos++
+	}
+
+	if filter.MemberID != "" {
+		add(" AND EXISTS (SELECT 1 FROM project_members pm WHERE pm.project_id = d.project_id AND pm.user_id = $%d)", filter.MemberID)
+	}
+	if len(filter.Projects) > 0 {
+		add(" AND d.project_id = ANY($%d::text[]::uuid[])", filter.Projects)
+	}
+	if len(filter.Statuses) > 0 {
+		add(" AND d.status = ANY($%d::text[])", filter.Statuses)
+	}
+	if len(filter.Priorities) > 0 {
+		add(" AND d.priority::text = ANY($%d::text[])", filter.Priorities)
+	}
+	if len(filter.Severities) > 0 {
+		add(" AND d.severity::text = ANY($%d::text[])", filter.Severities)
+	}
+
+	if len(filter.Assignees) > 0 {
+		ids := make([]string, 0, len(filter.Assignees))
+		unassigned := false
+		for _, value := range filter.Assignees {
+			if value == domain.AssigneeNone {
+				unassigned = true
+				continue
+			}
+			ids = append(ids, value)
+		}
+		switch {
+		case len(ids) > 0 && unassigned:
+			add(" AND (d.assignee_id IS NULL OR d.assignee_id = ANY($%d::text[]::uuid[]))", ids)
+		case len(ids) > 0:
+			add(" AND d.assignee_id = ANY($%d::text[]::uuid[])", ids)
+		default:
+			queryBuilder.WriteString(" AND d.assignee_id IS NULL")
+		}
+	}
+	if len(filter.Creators) > 0 {
+		add(" AND d.created_by = ANY($%d::text[]::uuid[])", filter.Creators)
+	}
+
+	if filter.CreatedFrom != nil {
+		add(" AND d.created_at >= $%d::date", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add(" AND d.created_at < $%d::date + 1", *filter.CreatedTo)
+	}
+	if filter.UpdatedFrom != nil {
+		add(" AND d.updated_at >= $%d::date", *filter.UpdatedFrom)
+	}
+	if filter.UpdatedTo != nil {
+		add(" AND d.updated_at < $%d::date + 1", *filter.UpdatedTo)
+	}
+	if filter.DueFrom != nil {
+		add(" AND d.due_date >= $%d::date", *filter.DueFrom)
+	}
+	if filter.DueTo != nil {
+		add(" AND d.due_date <= $%d::date", *filter.DueTo)
+	}
+
+	if filter.Query != "" {
+		queryBuilder.WriteString(fmt.Sprintf(`
+			AND (d.search_vector @@ %[1]s OR EXISTS (
+				SELECT 1 FROM defect_comments c
+				WHERE c.defect_id = d.id AND c.search_vector @@ %[1]s
+			))`, searchQuery(argPos)))
+		args = append(args, filter.Query)
+		argPos++
+	}
+
+	if filter.HasGeotaggedPhoto {
+		queryBuilder.WriteString(" AND EXISTS (SELECT 1 FROM defect_attachments a WHERE a.defect_id = d.id AND a.gps_latitude IS NOT NULL AND a.deleted_at IS NULL AND a.superseded_at IS NULL)")
+	}
+
+	if filter.Overdue {
+		queryBuilder.WriteString(" AND d.due_date < CURRENT_DATE AND NOT " + defectIsFinal)
+	}
+
+	return queryBuilder.String(), args
 }
 
 func (r *DefectRepository) Create(ctx context.Context, payload domain.DefectCreate) (domain.Defect, error) {
@@ -115,15 +412,16 @@ func (r *DefectRepository) Create(ctx context.Context, payload domain.DefectCrea
 		due.Time = *payload.DueDate
 	}
 
-	err := r.pool.QueryRow(ctx, `
+	err := r.db(ctx).QueryRow(ctx, `
 		INSERT INTO defects (project_id, title, description, priority, severity, status, assignee_id, due_date, created_by, updated_by)
-		VALUES ($1, $2, $3, $4, $5, 'NEW', $6, $7, $8, $8)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
 		RETURNING id, created_at, updated_at`,
 		payload.ProjectID,
 		payload.Title,
 		payload.Description,
 		payload.Priority,
 		payload.Severity,
+		payload.Status,
 		nullIfEmpty(payload.AssigneeID),
 		dateOrNil(payload.DueDate),
 		payload.CreatedBy,
@@ -137,7 +435,7 @@ func (r *DefectRepository) Create(ctx context.Context, payload domain.DefectCrea
 	defect.Description = payload.Description
 	defect.Priority = payload.Priority
 	defect.Severity = payload.Severity
-	defect.Status = "NEW"
+	defect.Status = payload.Status
 	defect.AssigneeID = payload.AssigneeID
 	defect.DueDate = payload.DueDate
 	defect.CreatedBy = payload.CreatedBy
@@ -153,7 +451,7 @@ func (r *DefectRepository) GetByID(ctx context.Context, id string) (domain.Defec
 		assName sql.NullString
 	)
 
-	err := r.pool.QueryRow(ctx, `
+	err := r.db(ctx).QueryRow(ctx, `
 		SELECT
 			d.id,
 			d.project_id,
@@ -166,6 +464,7 @@ func (r *DefectRepository) GetByID(ctx context.Context, id string) (domain.Defec
 			d.assignee_id,
 			COALESCE(u.full_name, '') AS assignee_name,
 			d.due_date,
+			d.reopen_count,
 			d.created_by,
 			d.created_at,
 			d.updated_at
@@ -186,6 +485,7 @@ func (r *DefectRepository) GetByID(ctx context.Context, id string) (domain.Defec
 		&assID,
 		&assName,
 		&due,
+		&defect.ReopenCount,
 		&defect.CreatedBy,
 		&defect.CreatedAt,
 		&defect.UpdatedAt,
@@ -218,9 +518,18 @@ func (r *DefectRepository) GetByID(ctx context.Context, id string) (domain.Defec
 	return defect, nil
 }
 
+func (r *DefectRepository) GetProjectID(ctx context.Context, id string) (string, error) {
+	var projectID string
+	err := r.db(ctx).QueryRow(ctx, `SELECT project_id FROM defects WHERE id = $1`, id).Scan(&projectID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", domain.ErrDefectNotFound
+	}
+	return projectID, err
+}
+
 func (r *DefectRepository) AddComment(ctx context.Context, payload domain.CommentCreate) (domain.Comment, error) {
 	var comment domain.Comment
-	err := r.pool.QueryRow(ctx, `
+	err := r.db(ctx).QueryRow(ctx, `
 		INSERT INTO defect_comments (defect_id, author_id, body)
 		VALUES ($1, $2, $3)
 		RETURNING id, created_at`,
@@ -235,7 +544,7 @@ func (r *DefectRepository) AddComment(ctx context.Context, payload domain.Commen
 	comment.AuthorID = payload.AuthorID
 	comment.Body = payload.Body
 
-	err = r.pool.QueryRow(ctx, `SELECT full_name FROM users WHERE id = $1`, payload.AuthorID).Scan(&comment.AuthorName)
+	err = r.db(ctx).QueryRow(ctx, `SELECT full_name FROM users WHERE id = $1`, payload.AuthorID).Scan(&comment.AuthorName)
 	if err != nil {
 		comment.AuthorName = "Неизвестно"
 	}
@@ -243,7 +552,7 @@ func (r *DefectRepository) AddComment(ctx context.Context, payload domain.Commen
 }
 
 func (r *DefectRepository) ListComments(ctx context.Context, defectID string) ([]domain.Comment, error) {
-	rows, err := r.pool.Query(ctx, `
+	rows, err := r.db(ctx).Query(ctx, `
 		SELECT c.id, c.author_id, COALESCE(u.full_name, ''), c.body, c.created_at
 		FROM defect_comments c
 		LEFT JOIN users u ON u.id = c.author_id
@@ -267,82 +576,525 @@ func (r *DefectRepository) ListComments(ctx context.Context, defectID string) ([
 	return comments, rows.Err()
 }
 
+const attachmentColumns = `a.defect_id, a.id, a.filename, a.content_type, a.size_bytes, a.storage_key,
+	COALESCE(a.checksum_sha256, ''), a.has_thumbnails, a.taken_at, a.gps_latitude, a.gps_longitude, COALESCE(a.camera_model, ''),
+	COALESCE(a.uploaded_by::text, ''), a.created_at, a.version, COALESCE(a.version_of::text, ''), a.superseded_at,
+	a.scan_status, COALESCE(a.scan_signature, ''), a.scanned_at`
+
+// attachmentFields returns scan targets matching attachmentColumns.
+func attachmentFields(att *domain.Attachment) []any {
+	return []any{&att.DefectID, &att.ID, &att.Filename, &att.ContentType, &att.SizeBytes, &att.StorageKey,
+		&att.Checksum, &att.HasThumbnails, &att.Photo.TakenAt, &att.Photo.Latitude, &att.Photo.Longitude, &att.Photo.CameraModel,
+		&att.UploadedBy, &att.UploadedAt, &att.Version, &att.VersionOf, &att.SupersededAt,
+		&att.ScanStatus, &att.ScanSignature, &att.ScannedAt}
+}
+
+func scanAttachment(row pgx.Row) (domain.Attachment, error) {
+	var att domain.Attachment
+	err := row.Scan(attachmentFields(&att)...)
+	return att, err
+}
+
+func scanAttachments(rows pgx.Rows) ([]domain.Attachment, error) {
+	defer rows.Close()
+	var attachments []domain.Attachment
+	for rows.Next() {
+		att, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, att)
+	}
+	return attachments, rows.Err()
+}
+
+// AddAttachment inserts the attachment. With ReplacesID set the row becomes the
+// next version of that attachment; superseding the previous one is up to the caller.
 func (r *DefectRepository) AddAttachment(ctx context.Context, payload domain.AttachmentCreate) (domain.Attachment, error) {
-	var attachment domain.Attachment
-	err := r.pool.QueryRow(ctx, `
-		INSERT INTO defect_attachments (defect_id, filename, content_type, size_bytes, storage_key)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at`,
+	return scanAttachment(r.db(ctx).QueryRow(ctx, `
+		WITH previous AS (
+			SELECT version, COALESCE(version_of, id) AS root_id
+			FROM defect_attachments
+			WHERE id = $12
+		)
+		INSERT INTO defect_attachments AS a (defect_id, filename, content_type, size_bytes, storage_key, has_thumbnails,
+			taken_at, gps_latitude, gps_longitude, camera_model, uploaded_by, checksum_sha256, version, version_of)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $13,
+			COALESCE((SELECT version + 1 FROM previous), 1),
+			(SELECT root_id FROM previous))
+		RETURNING `+attachmentColumns,
 		payload.DefectID,
 		payload.Filename,
 		payload.ContentType,
 		payload.SizeBytes,
 		payload.StorageKey,
-	).Scan(&attachment.ID, &attachment.UploadedAt)
+		payload.HasThumbnails,
+		payload.Photo.TakenAt,
+		payload.Photo.Latitude,
+		payload.Photo.Longitude,
+		nullIfEmpty(payload.Photo.CameraModel),
+		nullIfEmpty(payload.UploadedBy),
+		nullIfEmpty(payload.ReplacesID),
+		nullIfEmpty(payload.Checksum),
+	))
+}
+
+// SupersedeAttachment marks the attachment as replaced by a newer version and
+// returns domain.ErrAttachmentSuperseded if it already was.
+func (r *DefectRepository) SupersedeAttachment(ctx context.Context, defectID, attachmentID string) error {
+	tag, err := r.db(ctx).Exec(ctx, `
+		UPDATE defect_attachments SET superseded_at = NOW()
+		WHERE defect_id = $1 AND id = $2 AND superseded_at IS NULL AND deleted_at IS NULL`,
+		defectID, attachmentID,
+	)
 	if err != nil {
-		return domain.Attachment{}, err
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrAttachmentSuperseded
 	}
+	return nil
+}
 
-	attachment.DefectID = payload.DefectID
-	attachment.Filename = payload.Filename
-	attachment.ContentType = payload.ContentType
-	attachment.SizeBytes = payload.SizeBytes
-	attachment.StorageKey = payload.StorageKey
-	return attachment, nil
+// AttachmentsSize returns the total size of the defect attachments in bytes,
+// previous versions included.
+func (r *DefectRepository) AttachmentsSize(ctx context.Context, defectID string) (int64, error) {
+	var total int64
+	err := r.db(ctx).QueryRow(ctx, `
+		SELECT COALESCE(SUM(size_bytes), 0)::bigint
+		FROM defect_attachments
+		WHERE defect_id = $1 AND deleted_at IS NULL`,
+		defectID,
+	).Scan(&total)
+	return total, err
 }
 
+// ListAttachments returns the latest versions of non-deleted attachments.
 func (r *DefectRepository) ListAttachments(ctx context.Context, defectID string) ([]domain.Attachment, error) {
-	rows, err := r.pool.Query(ctx, `
-		SELECT defect_id, id, filename, content_type, size_bytes, storage_key, created_at
-		FROM defect_attachments
-		WHERE defect_id = $1
-		ORDER BY created_at DESC`,
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM defect_attachments a
+		WHERE a.defect_id = $1 AND a.deleted_at IS NULL AND a.superseded_at IS NULL
+		ORDER BY a.created_at DESC`,
 		defectID,
 	)
 	if err != nil {
 		return nil, err
 	}
+	return scanAttachments(rows)
+}
+
+// ListExportAttachments returns the latest versions of non-deleted attachments
+// of a defect or of all defects of a project (pass an empty id to skip a
+// filter), grouped by defect.
+func (r *DefectRepository) ListExportAttachments(ctx context.Context, projectID, defectID string) ([]domain.ExportedAttachment, error) {
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT `+attachmentColumns+`, d.title, COALESCE(u.full_name, '')
+		FROM defect_attachments a
+		JOIN defects d ON d.id = a.defect_id
+		LEFT JOIN users u ON u.id = a.uploaded_by
+		WHERE a.deleted_at IS NULL AND a.superseded_at IS NULL
+			AND ($1::uuid IS NULL OR d.project_id = $1)
+			AND ($2::uuid IS NULL OR d.id = $2)
+		ORDER BY d.created_at, d.id, a.created_at`,
+		nullIfEmpty(projectID), nullIfEmpty(defectID),
+	)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var attachments []domain.Attachment
+	var items []domain.ExportedAttachment
 	for rows.Next() {
-		var att domain.Attachment
-		if err := rows.Scan(&att.DefectID, &att.ID, &att.Filename, &att.ContentType, &att.SizeBytes, &att.StorageKey, &att.UploadedAt); err != nil {
+		var item domain.ExportedAttachment
+		if err := rows.Scan(append(attachmentFields(&item.Attachment), &item.DefectTitle, &item.UploaderName)...); err != nil {
 			return nil, err
 		}
-		attachments = append(attachments, att)
+		items = append(items, item)
 	}
-	return attachments, rows.Err()
+	return items, rows.Err()
+}
+
+// ListAttachmentVersions returns all versions sharing rootID, newest first.
+func (r *DefectRepository) ListAttachmentVersions(ctx context.Context, defectID, rootID string) ([]domain.Attachment, error) {
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM defect_attachments a
+		WHERE a.defect_id = $1 AND COALESCE(a.version_of, a.id) = $2 AND a.deleted_at IS NULL
+		ORDER BY a.version DESC`,
+		defectID, rootID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanAttachments(rows)
 }
 
+// GetAttachment returns any non-deleted version of the attachment.
 func (r *DefectRepository) GetAttachment(ctx context.Context, defectID, attachmentID string) (domain.Attachment, error) {
-	var att domain.Attachment
-	err := r.pool.QueryRow(ctx, `
-		SELECT defect_id, id, filename, content_type, size_bytes, storage_key, created_at
-		FROM defect_attachments
-		WHERE defect_id = $1 AND id = $2`,
+	return scanAttachment(r.db(ctx).QueryRow(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM defect_attachments a
+		WHERE a.defect_id = $1 AND a.id = $2 AND a.deleted_at IS NULL`,
 		defectID, attachmentID,
-	).Scan(&att.DefectID, &att.ID, &att.Filename, &att.ContentType, &att.SizeBytes, &att.StorageKey, &att.UploadedAt)
-	return att, err
+	))
+}
+
+// DeleteAttachment soft-deletes all versions sharing rootID and returns them so
+// that their blobs can be purged from the storage. Location, capture time and
+// camera model are personal data and are cleared right away.
+func (r *DefectRepository) DeleteAttachment(ctx context.Context, defectID, rootID, actorID string) ([]domain.Attachment, error) {
+	rows, err := r.db(ctx).Query(ctx, `
+		UPDATE defect_attachments a SET deleted_at = NOW(), deleted_by = $3,
+			gps_latitude = NULL, gps_longitude = NULL, taken_at = NULL, camera_model = NULL
+		WHERE a.defect_id = $1 AND COALESCE(a.version_of, a.id) = $2 AND a.deleted_at IS NULL
+		RETURNING `+attachmentColumns,
+		defectID, rootID, actorID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanAttachments(rows)
+}
+
+// SetScanResult records the malware scan verdict for every non-deleted
+// attachment stored under the key: deduplicated rows share the content.
+func (r *DefectRepository) SetScanResult(ctx context.Context, storageKey, status, signature string) error {
+	_, err := r.db(ctx).Exec(ctx, `
+		UPDATE defect_attachments
+		SET scan_status = $2, scan_signature = $3, scanned_at = NOW()
+		WHERE storage_key = $1 AND deleted_at IS NULL`,
+		storageKey, status, nullIfEmpty(signature),
+	)
+	return err
+}
+
+// ListPendingScans returns up to limit non-deleted attachments uploaded before
+// the given time that still wait for the malware scan, oldest first. A non-nil
+// after continues the listing past that attachment.
+func (r *DefectRepository) ListPendingScans(ctx context.Context, before time.Time, after *domain.Attachment, limit int) ([]domain.Attachment, error) {
+	var afterCreated *time.Time
+	var afterID *string
+	if after != nil {
+		afterCreated, afterID = &after.UploadedAt, &after.ID
+	}
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM defect_attachments a
+		WHERE a.scan_status = 'pending_scan' AND a.deleted_at IS NULL AND a.created_at < $1
+		  AND ($2::timestamptz IS NULL OR (a.created_at, a.id) > ($2, $3::uuid))
+		ORDER BY a.created_at, a.id
+		LIMIT $4`,
+		before, afterCreated, afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanAttachments(rows)
+}
+
+// ReferencedStorageKeys returns keys of blobs still used by attachments
+// (all versions, excluding deleted ones) mapped to whether they have thumbnails.
+func (r *DefectRepository) ReferencedStorageKeys(ctx context.Context) (map[string]bool, error) {
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT storage_key, has_thumbnails
+		FROM defect_attachments
+		WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var (
+			key           string
+			hasThumbnails bool
+		)
+		if err := rows.Scan(&key, &hasThumbnails); err != nil {
+			return nil, err
+		}
+		keys[key] = keys[key] || hasThumbnails
+	}
+	return keys, rows.Err()
+}
+
+const blobColumns = `storage_key, COALESCE(checksum_sha256, ''), size_bytes, has_thumbnails, ref_count`
+
+func scanBlob(row pgx.Row) (domain.Blob, error) {
+	var blob domain.Blob
+	err := row.Scan(&blob.StorageKey, &blob.Checksum, &blob.SizeBytes, &blob.HasThumbnails, &blob.RefCount)
+	return blob, err
+}
+
+// FindBlob returns the stored file with the given SHA-256.
+func (r *DefectRepository) FindBlob(ctx context.Context, checksum string) (domain.Blob, error) {
+	return scanBlob(r.db(ctx).QueryRow(ctx, `
+		SELECT `+blobColumns+`
+		FROM attachment_blobs
+		WHERE checksum_sha256 = $1`,
+		checksum,
+	))
+}
+
+// AcquireBlob takes a reference to the file with the blob checksum, registering
+// the given blob when there is none yet. The returned blob is the one the
+// attachment must point to; it differs from the argument for duplicates.
+func (r *DefectRepository) AcquireBlob(ctx context.Context, blob domain.Blob) (domain.Blob, error) {
+	return scanBlob(r.db(ctx).QueryRow(ctx, `
+		INSERT INTO attachment_blobs (storage_key, checksum_sha256, size_bytes, has_thumbnails, ref_count)
+		VALUES ($1, $2, $3, $4, 1)
+		ON CONFLICT (checksum_sha256) DO UPDATE SET ref_count = attachment_blobs.ref_count + 1
+		RETURNING `+blobColumns,
+		blob.StorageKey,
+		nullIfEmpty(blob.Checksum),
+		blob.SizeBytes,
+		blob.HasThumbnails,
+	))
+}
+
+// ReleaseBlobs drops one reference per key (a key may repeat) and returns the
+// blobs nobody references anymore. Their rows are removed, the files are left
+// to the caller.
+func (r *DefectRepository) ReleaseBlobs(ctx context.Context, storageKeys []string) ([]domain.Blob, error) {
+	if len(storageKeys) == 0 {
+		return nil, nil
+	}
+	_, err := r.db(ctx).Exec(ctx, `
+		UPDATE attachment_blobs b SET ref_count = GREATEST(b.ref_count - released.n, 0)
+		FROM (
+			SELECT key, COUNT(*)::int AS n
+			FROM unnest($1::text[]) AS key
+			GROUP BY key
+		) released
+		WHERE b.storage_key = released.key`,
+		storageKeys,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db(ctx).Query(ctx, `
+		DELETE FROM attachment_blobs
+		WHERE storage_key = ANY($1::text[]) AND ref_count = 0
+		RETURNING `+blobColumns,
+		storageKeys,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var released []domain.Blob
+	for rows.Next() {
+		blob, err := scanBlob(rows)
+		if err != nil {
+			return nil, err
+		}
+		released = append(released, blob)
+	}
+	return released, rows.Err()
+}
+
+// ListLiveBlobs returns the stored files referenced by attachments that are not
+// deleted, superseded versions included. attachment_blobs is not used: it may
+// keep rows of cascaded deletes until the blob collector prunes them.
+func (r *DefectRepository) ListLiveBlobs(ctx context.Context) ([]domain.Blob, error) {
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT a.storage_key, COALESCE(MAX(b.checksum_sha256), MAX(a.checksum_sha256), ''),
+			MAX(a.size_bytes), BOOL_OR(a.has_thumbnails), COUNT(*)
+		FROM defect_attachments a
+		LEFT JOIN attachment_blobs b ON b.storage_key = a.storage_key
+		WHERE a.deleted_at IS NULL
+		GROUP BY a.storage_key
+		ORDER BY MIN(a.created_at), a.storage_key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs []domain.Blob
+	for rows.Next() {
+		blob, err := scanBlob(rows)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs, rows.Err()
+}
+
+// RenameBlob points the blob and every attachment row, deleted ones included,
+// to a new storage key.
+func (r *DefectRepository) RenameBlob(ctx context.Context, oldKey, newKey string) error {
+	_, err := r.db(ctx).Exec(ctx, `
+		WITH renamed AS (
+			UPDATE attachment_blobs SET storage_key = $2 WHERE storage_key = $1
+		)
+		UPDATE defect_attachments SET storage_key = $2 WHERE storage_key = $1`,
+		oldKey, newKey,
+	)
+	return err
 }
 
-func (r *DefectRepository) UpdateStatus(ctx context.Context, id, status, actorID string) error {
-	_, err := r.pool.Exec(ctx, `
+// PruneUnreferencedBlobs removes blob rows without live attachments. Reference
+// counts are kept by the application, so rows removed by cascades (e.g. a
+// deleted project) leave such blobs behind; their files become orphans.
+func (r *DefectRepository) PruneUnreferencedBlobs(ctx context.Context) (int64, error) {
+	tag, err := r.db(ctx).Exec(ctx, `
+		DELETE FROM attachment_blobs b
+		WHERE NOT EXISTS (
+			SELECT 1 FROM defect_attachments a
+			WHERE a.storage_key = b.storage_key AND a.deleted_at IS NULL
+		)`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// LockForUpdate takes a row lock on the defect until the surrounding transaction ends.
+func (r *DefectRepository) LockForUpdate(ctx context.Context, id string) error {
+	var lockedID string
+	err := r.db(ctx).QueryRow(ctx, `SELECT id FROM defects WHERE id = $1 FOR UPDATE`, id).Scan(&lockedID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrDefectNotFound
+	}
+	return err
+}
+
+// UpdateStatus moves the defect from fromStatus to toStatus and reports
+// domain.ErrConflict if the status was changed by someone else meanwhile.
+func (r *DefectRepository) UpdateStatus(ctx context.Context, id, fromStatus, toStatus, actorID string) error {
+	tag, err := r.db(ctx).Exec(ctx, `
 		UPDATE defects SET status = $1, updated_by = $2, updated_at = NOW()
-		WHERE id = $3`,
-		status, actorID, id,
+		WHERE id = $3 AND status = $4`,
+		toStatus, actorID, id, fromStatus,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+func (r *DefectRepository) Update(ctx context.Context, id string, payload domain.DefectUpdate) error {
+	queryBuilder := strings.Builder{}
+	queryBuilder.WriteString("UPDATE defects SET updated_by = $1, updated_at = NOW()")
+
+	args := []any{payload.UpdatedBy}
+	argPos := 2
+
+	set := func(column string, value any) {
+		queryBuilder.WriteString(fmt.Sprintf(", %s = $%d", column, argPos))
+		args = append(args, value)
+		argPos++
+	}
+
+	if payload.Title != nil {
+		set("title", *payload.Title)
+	}
+	if payload.Description != nil {
+		set("description", *payload.Description)
+	}
+	if payload.Priority != nil {
+		set("priority", *payload.Priority)
+	}
+	if payload.Severity != nil {
+		set("severity", *payload.Severity)
+	}
+	if payload.AssigneeID != nil {
+		set("assignee_id", nullIfEmpty(*payload.AssigneeID))
+	}
+	if payload.ClearDueDate {
+		set("due_date", nil)
+	} else if payload.DueDate != nil {
+		set("due_date", dateOrNil(payload.DueDate))
+	}
+
+	queryBuilder.WriteString(fmt.Sprintf(" WHERE id = $%d", argPos))
+	args = append(args, id)
+
+	_, err := r.db(ctx).Exec(ctx, queryBuilder.String(), args...)
+	return err
+}
+
+// IncrementReopenCount bumps the counter used for contractor quality reporting.
+func (r *DefectRepository) IncrementReopenCount(ctx context.Context, id string) error {
+	_, err := r.db(ctx).Exec(ctx, `UPDATE defects SET reopen_count = reopen_count + 1 WHERE id = $1`, id)
+	return err
+}
+
+// AddStatusHistory records a status transition together with its reason.
+func (r *DefectRepository) AddStatusHistory(ctx context.Context, defectID, actorID, oldStatus, newStatus, reason string) error {
+	_, err := r.db(ctx).Exec(ctx, `
+		INSERT INTO defect_history (defect_id, actor_id, field, old_value, new_value, reason)
+		VALUES ($1, $2, 'status', $3, $4, $5)`,
+		defectID, actorID, oldStatus, newStatus, nullIfEmpty(reason),
 	)
 	return err
 }
 
 func (r *DefectRepository) AddHistory(ctx context.Context, defectID, actorID, field, oldValue, newValue string) error {
-	_, err := r.pool.Exec(ctx, `
+	_, err := r.db(ctx).Exec(ctx, `
 		INSERT INTO defect_history (defect_id, actor_id, field, old_value, new_value)
 		VALUES ($1, $2, $3, $4, $5)`,
 		defectID, actorID, field, oldValue, newValue,
 	)
 	return err
 }
+
+// ListHistory returns history entries of the defect (oldest first) and their total count.
+// A non-positive limit returns all entries.
+func (r *DefectRepository) ListHistory(ctx context.Context, defectID string, limit, offset int) ([]domain.HistoryEntry, int, error) {
+	var total int
+	if err := r.db(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM defect_history WHERE defect_id = $1`, defectID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT h.id, h.defect_id, h.actor_id, COALESCE(u.full_name, ''), h.field,
+			COALESCE(h.old_value, ''), COALESCE(h.new_value, ''), COALESCE(h.reason, ''), h.created_at
+		FROM defect_history h
+		LEFT JOIN users u ON u.id = h.actor_id
+		WHERE h.defect_id = $1
+		ORDER BY h.created_at ASC, h.id ASC
+		OFFSET $2`
+	args := []any{defectID, offset}
+	if limit > 0 {
+		query += " LIMIT $3"
+		args = append(args, limit)
+	}
+
+	rows, err := r.db(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []domain.HistoryEntry
+	for rows.Next() {
+		var entry domain.HistoryEntry
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.DefectID,
+			&entry.ActorID,
+			&entry.ActorName,
+			&entry.Field,
+			&entry.OldValue,
+			&entry.NewValue,
+			&entry.Reason,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
 func nullIfEmpty(value string) any {
 	if strings.TrimSpace(value) == "" {
 		return nil
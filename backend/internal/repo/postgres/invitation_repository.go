@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"defect-tracker/internal/domain"
+)
+
+type InvitationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewInvitationRepository(pool *pgxpool.Pool) *InvitationRepository {
+	return &InvitationRepository{pool: pool}
+}
+
+// db returns the transaction bound to ctx, if any, or the pool.
+func (r *InvitationRepository) db(ctx context.Context) querier {
+	return conn(ctx, r.pool)
+}
+
+const invitationColumns = `i.id, i.email, i.project_id, p.name, i.role::text, COALESCE(i.invited_by::text, ''),
+	i.expires_at, i.accepted_at, i.created_at`
+
+func scanInvitation(row pgx.Row) (domain.Invitation, error) {
+	var inv domain.Invitation
+	err := row.Scan(&inv.ID, &inv.Email, &inv.ProjectID, &inv.ProjectName, &inv.Role, &inv.InvitedBy,
+		&inv.ExpiresAt, &inv.AcceptedAt, &inv.CreatedAt)
+	return inv, err
+}
+
+// Create stores an invitation and replaces pending ones for the same email and
+// project, so inviting again simply resends. It returns
+// domain.ErrEmailAlreadyExists when the email already has an account.
+func (r *InvitationRepository) Create(ctx context.Context, payload domain.InvitationCreate) (domain.Invitation, error) {
+	inv, err := scanInvitation(r.db(ctx).QueryRow(ctx, `
+		WITH replaced AS (
+			DELETE FROM invitations
+			WHERE email = $1 AND project_id = $2 AND accepted_at IS NULL
+		), i AS (
+			INSERT INTO invitations (email, project_id, role, invited_by, expires_at)
+			SELECT $1, $2, $3, $4, $5
+			WHERE NOT EXISTS (SELECT 1 FROM users WHERE email = $1)
+			RETURNING *
+		)
+		SELECT `+invitationColumns+`
+		FROM i
+		JOIN projects p ON p.id = i.project_id`,
+		payload.Email, payload.ProjectID, payload.Role, payload.InvitedBy, payload.ExpiresAt,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Invitation{}, domain.ErrEmailAlreadyExists
+	}
+	return inv, err
+}
+
+func (r *InvitationRepository) GetByID(ctx context.Context, id string) (domain.Invitation, error) {
+	inv, err := scanInvitation(r.db(ctx).QueryRow(ctx, `
+		SELECT `+invitationColumns+`
+		FROM invitations i
+		JOIN projects p ON p.id = i.project_id
+		WHERE i.id = $1`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Invitation{}, domain.ErrInvitationNotFound
+	}
+	return inv, err
+}
+
+// ListPending returns invitations of the project that were not accepted yet,
+// expired ones included so that managers can resend them.
+func (r *InvitationRepository) ListPending(ctx context.Context, projectID string) ([]domain.Invitation, error) {
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT `+invitationColumns+`
+		FROM invitations i
+		JOIN projects p ON p.id = i.project_id
+		WHERE i.project_id = $1 AND i.accepted_at IS NULL
+		ORDER BY i.created_at DESC`,
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []domain.Invitation
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// Revoke deletes a pending invitation; its token stops working.
+func (r *InvitationRepository) Revoke(ctx context.Context, projectID, id string) error {
+	tag, err := r.db(ctx).Exec(ctx, `
+		DELETE FROM invitations
+		WHERE id = $1 AND project_id = $2 AND accepted_at IS NULL`,
+		id, projectID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrInvitationNotFound
+	}
+	return nil
+}
+
+// MarkAccepted marks the invitation accepted and returns it. It returns
+// domain.ErrInvitationInvalid if the invitation was accepted, revoked or has
+// expired meanwhile.
+func (r *InvitationRepository) MarkAccepted(ctx context.Context, id string) (domain.Invitation, error) {
+	inv := domain.Invitation{ID: id}
+	err := r.db(ctx).QueryRow(ctx, `
+		UPDATE invitations SET accepted_at = NOW()
+		WHERE id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		RETURNING email, project_id, role::text, accepted_at`,
+		id,
+	).Scan(&inv.Email, &inv.ProjectID, &inv.Role, &inv.AcceptedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Invitation{}, domain.ErrInvitationInvalid
+	}
+	return inv, err
+}
+
+// CreateAccount creates the account of an invitee. Invited people get the
+// engineer global role; the invited role applies to the project only.
+func (r *InvitationRepository) CreateAccount(ctx context.Context, email, fullName, passwordHash string) (domain.User, error) {
+	user, err := scanUser(r.db(ctx).QueryRow(ctx, `
+		INSERT INTO users (email, full_name, role, password_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+userColumns,
+		email, fullName, domain.RoleEngineer, passwordHash,
+	))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.User{}, domain.ErrEmailAlreadyExists
+		}
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+// AddMember adds the new account to the project it was invited to.
+func (r *InvitationRepository) AddMember(ctx context.Context, projectID, userID, role string) error {
+	_, err := r.db(ctx).Exec(ctx, `
+		INSERT INTO project_members (project_id, user_id, role)
+		VALUES ($1, $2, $3)`,
+		projectID, userID, role,
+	)
+	return err
+}
@@ -3,7 +3,11 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"defect-tracker/internal/domain"
@@ -17,11 +21,19 @@ func NewProjectRepository(pool *pgxpool.Pool) *ProjectRepository {
 	return &ProjectRepository{pool: pool}
 }
 
-func (r *ProjectRepository) List(ctx context.Context) ([]domain.Project, error) {
+const projectColumns = `p.id, p.name, p.stage, p.description, p.start_date, p.end_date,
+	COALESCE(p.workflow_id::text, ''), p.archived_at, p.created_by, p.created_at, p.updated_at`
+
+// List returns projects the user is a member of; archived ones only on request.
+func (r *ProjectRepository) List(ctx context.Context, userID string, includeArchived bool) ([]domain.Project, error) {
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, name, stage, description, start_date, end_date, created_by, created_at, updated_at
-		FROM projects
-		ORDER BY created_at DESC`)
+		SELECT `+projectColumns+`
+		FROM projects p
+		JOIN project_members pm ON pm.project_id = p.id AND pm.user_id = $1
+		WHERE $2 OR p.archived_at IS NULL
+		ORDER BY p.created_at DESC`,
+		userID, includeArchived,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -29,43 +41,73 @@ func (r *ProjectRepository) List(ctx context.Context) ([]domain.Project, error)
 
 	var projects []domain.Project
 	for rows.Next() {
-		var (
-			project domain.Project
-			start   sql.NullTime
-			end     sql.NullTime
-		)
-
-		if err := rows.Scan(
-			&project.ID,
-			&project.Name,
-			&project.Stage,
-			&project.Description,
-			&start,
-			&end,
-			&project.CreatedBy,
-			&project.CreatedAt,
-			&project.UpdatedAt,
-		); err != nil {
+		project, err := scanProject(rows)
+		if err != nil {
 			return nil, err
 		}
-		if start.Valid {
-			project.StartDate = &start.Time
-		}
-		if end.Valid {
-			project.EndDate = &end.Time
-		}
 		projects = append(projects, project)
 	}
 	return projects, rows.Err()
 }
 
+func (r *ProjectRepository) GetByID(ctx context.Context, id string) (domain.Project, error) {
+	return scanProject(r.pool.QueryRow(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects p
+		WHERE p.id = $1`,
+		id,
+	))
+}
+
+func scanProject(row pgx.Row) (domain.Project, error) {
+	var (
+		project  domain.Project
+		start    sql.NullTime
+		end      sql.NullTime
+		archived sql.NullTime
+	)
+
+	if err := row.Scan(
+		&project.ID,
+		&project.Name,
+		&project.Stage,
+		&project.Description,
+		&start,
+		&end,
+		&project.WorkflowID,
+		&archived,
+		&project.CreatedBy,
+		&project.CreatedAt,
+		&project.UpdatedAt,
+	); err != nil {
+		return domain.Project{}, err
+	}
+	if start.Valid {
+		project.StartDate = &start.Time
+	}
+	if end.Valid {
+		project.EndDate = &end.Time
+	}
+	if archived.Valid {
+		project.ArchivedAt = &archived.Time
+	}
+	return project, nil
+}
+
+// Create inserts the project and makes its author a project manager.
 func (r *ProjectRepository) Create(ctx context.Context, payload domain.ProjectCreate) (domain.Project, error) {
 	var project domain.Project
 
 	err := r.pool.QueryRow(ctx, `
-		INSERT INTO projects (name, stage, description, start_date, end_date, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at, updated_at`,
+		WITH created AS (
+			INSERT INTO projects (name, stage, description, start_date, end_date, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at, updated_at
+		), author AS (
+			INSERT INTO project_members (project_id, user_id, role)
+			SELECT id, $6, 'manager' FROM created
+		)
+		SELECT id, created_at, updated_at FROM created`,
 		payload.Name,
 		payload.Stage,
 		payload.Description,
@@ -86,3 +128,148 @@ func (r *ProjectRepository) Create(ctx context.Context, payload domain.ProjectCr
 
 	return project, nil
 }
+
+func (r *ProjectRepository) Update(ctx context.Context, id string, payload domain.ProjectUpdate) error {
+	queryBuilder := strings.Builder{}
+	queryBuilder.WriteString("UPDATE projects SET updated_at = NOW()")
+
+	args := make([]any, 0, 6)
+	argPos := 1
+
+	set := func(column string, value any) {
+		queryBuilder.WriteString(fmt.Sprintf(", %s = $%d", column, argPos))
+		args = append(args, value)
+		argPos++
+	}
+
+	if payload.Name != nil {
+		set("name", *payload.Name)
+	}
+	if payload.Stage != nil {
+		set("stage", *payload.Stage)
+	}
+	if payload.Description != nil {
+		set("description", *payload.Description)
+	}
+	if payload.ClearStartDate {
+		set("start_date", nil)
+	} else if payload.StartDate != nil {
+		set("start_date", dateOrNil(payload.StartDate))
+	}
+	if payload.ClearEndDate {
+		set("end_date", nil)
+	} else if payload.EndDate != nil {
+		set("end_date", dateOrNil(payload.EndDate))
+	}
+
+	queryBuilder.WriteString(fmt.Sprintf(" WHERE id = $%d", argPos))
+	args = append(args, id)
+
+	_, err := r.pool.Exec(ctx, queryBuilder.String(), args...)
+	return err
+}
+
+func (r *ProjectRepository) SetArchived(ctx context.Context, id string, archived bool) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE projects
+		SET archived_at = CASE WHEN $1 THEN COALESCE(archived_at, NOW()) END, updated_at = NOW()
+		WHERE id = $2`,
+		archived, id,
+	)
+	return err
+}
+
+// Delete removes the project (defects cascade) unless it still has defects
+// outside the final statuses of its workflow.
+func (r *ProjectRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM projects p
+		WHERE p.id = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM defects d
+				WHERE d.project_id = p.id
+					AND NOT `+defectIsFinal+`
+			)`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return pgx.ErrNoRows
+	}
+	return domain.ErrProjectHasOpenDefects
+}
+
+func (r *ProjectRepository) ListMembers(ctx context.Context, projectID string) ([]domain.ProjectMember, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT pm.project_id, pm.user_id, u.full_name, u.email, pm.role::text
+		FROM project_members pm
+		JOIN users u ON u.id = pm.user_id
+		WHERE pm.project_id = $1
+		ORDER BY pm.role, u.full_name`,
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []domain.ProjectMember
+	for rows.Next() {
+		var member domain.ProjectMember
+		if err := rows.Scan(&member.ProjectID, &member.UserID, &member.FullName, &member.Email, &member.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// GetMemberRole returns the per-project role or domain.ErrNoProjectAccess for non-members.
+func (r *ProjectRepository) GetMemberRole(ctx context.Context, projectID, userID string) (string, error) {
+	var role string
+	err := r.pool.QueryRow(ctx, `
+		SELECT role::text FROM project_members
+		WHERE project_id = $1 AND user_id = $2`,
+		projectID, userID,
+	).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", domain.ErrNoProjectAccess
+	}
+	return role, err
+}
+
+// SaveMember adds the user to the project or changes the role of an existing member.
+func (r *ProjectRepository) SaveMember(ctx context.Context, projectID, userID, role string) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO project_members (project_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+		projectID, userID, role,
+	)
+	return err
+}
+
+func (r *ProjectRepository) RemoveMember(ctx context.Context, projectID, userID string) error {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM project_members
+		WHERE project_id = $1 AND user_id = $2`,
+		projectID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrMemberNotFound
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"defect-tracker/internal/domain"
+)
+
+type UploadRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewUploadRepository(pool *pgxpool.Pool) *UploadRepository {
+	return &UploadRepository{pool: pool}
+}
+
+const uploadColumns = `id, defect_id, filename, size_bytes, chunk_size, offset_bytes, COALESCE(replaces_id::text, ''),
+	storage_key, storage_upload_id, hash_state, created_by, created_at, expires_at`
+
+func scanUpload(row pgx.Row) (domain.Upload, error) {
+	var upload domain.Upload
+	err := row.Scan(&upload.ID, &upload.DefectID, &upload.Filename, &upload.SizeBytes, &upload.ChunkSize, &upload.Offset,
+		&upload.ReplacesID, &upload.StorageKey, &upload.StorageUploadID, &upload.HashState, &upload.CreatedBy,
+		&upload.CreatedAt, &upload.ExpiresAt)
+	return upload, err
+}
+
+func (r *UploadRepository) Create(ctx context.Context, upload domain.Upload) (domain.Upload, error) {
+	return scanUpload(r.pool.QueryRow(ctx, `
+		INSERT INTO attachment_uploads (defect_id, filename, size_bytes, chunk_size, replaces_id,
+			storage_key, storage_upload_id, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+uploadColumns,
+		upload.DefectID,
+		upload.Filename,
+		upload.SizeBytes,
+		upload.ChunkSize,
+		nullIfEmpty(upload.ReplacesID),
+		upload.StorageKey,
+		upload.StorageUploadID,
+		upload.CreatedBy,
+		upload.ExpiresAt,
+	))
+}
+
+// Get returns the upload with its stored parts in order.
+func (r *UploadRepository) Get(ctx context.Context, id string) (domain.Upload, error) {
+	upload, err := scanUpload(r.pool.QueryRow(ctx, `SELECT `+uploadColumns+` FROM attachment_uploads WHERE id = $1`, id))
+	if err != nil {
+		return domain.Upload{}, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT number, etag, size_bytes
+		FROM attachment_upload_parts
+		WHERE upload_id = $1
+		ORDER BY number`,
+		id,
+	)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var part domain.UploadPart
+		if err := rows.Scan(&part.Number, &part.ETag, &part.SizeBytes); err != nil {
+			return domain.Upload{}, err
+		}
+		upload.Parts = append(upload.Parts, part)
+	}
+	return upload, rows.Err()
+}
+
+// Claim reserves the upload for writing at offset until the given time and
+// returns domain.ErrUploadBusy if another request holds it or the offset moved.
+func (r *UploadRepository) Claim(ctx context.Context, id string, offset int64, until time.Time) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE attachment_uploads SET locked_until = $3
+		WHERE id = $1 AND offset_bytes = $2 AND (locked_until IS NULL OR locked_until < NOW())`,
+		id, offset, until,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUploadBusy
+	}
+	return nil
+}
+
+// Release drops the claim without changing the upload.
+func (r *UploadRepository) Release(ctx context.Context, id string) error {
+	_, err := r.pool.Exec(ctx, `UPDATE attachment_uploads SET locked_until = NULL WHERE id = $1`, id)
+	return err
+}
+
+// Advance records a stored part, moves the offset past it and releases the claim.
+func (r *UploadRepository) Advance(ctx context.Context, id string, offset int64, part domain.UploadPart, hashState []byte) error {
+	tag, err := r.pool.Exec(ctx, `
+		WITH advanced AS (
+			UPDATE attachment_uploads
+			SET offset_bytes = offset_bytes + $5, hash_state = $6, locked_until = NULL
+			WHERE id = $1 AND offset_bytes = $2
+			RETURNING id
+		)
+		INSERT INTO attachment_upload_parts (upload_id, number, etag, size_bytes)
+		SELECT id, $3, $4, $5 FROM advanced`,
+		id, offset, part.Number, part.ETag, part.SizeBytes, hashState,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUploadOffsetMismatch
+	}
+	return nil
+}
+
+func (r *UploadRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM attachment_uploads WHERE id = $1`, id)
+	return err
+}
+
+// ListExpired returns up to limit uploads that expired before now.
+func (r *UploadRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Upload, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+uploadColumns+`
+		FROM attachment_uploads
+		WHERE expires_at < $1 AND (locked_until IS NULL OR locked_until < $1)
+		ORDER BY expires_at
+		LIMIT $2`,
+		now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []domain.Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, rows.Err()
+}
@@ -3,7 +3,10 @@ package postgres
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -18,24 +21,64 @@ func NewUserRepository(pool *pgxpool.Pool) *UserRepository {
 	return &UserRepository{pool: pool}
 }
 
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+const userColumns = `id, email, full_name, role, password_hash, is_active, created_at, updated_at`
+
+func scanUser(row pgx.Row) (domain.User, error) {
 	var user domain.User
-	err := r.pool.QueryRow(ctx, `
-		SELECT id, email, full_name, role, password_hash, created_at, updated_at
-		FROM users WHERE email = $1`,
-		email,
-	).Scan(&user.ID, &user.Email, &user.FullName, &user.Role, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	err := row.Scan(&user.ID, &user.Email, &user.FullName, &user.Role, &user.PasswordHash, &user.IsActive,
+		&user.CreatedAt, &user.UpdatedAt)
 	return user, err
 }
 
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
+
 func (r *UserRepository) GetByID(ctx context.Context, id string) (domain.User, error) {
-	var user domain.User
-	err := r.pool.QueryRow(ctx, `
-		SELECT id, email, full_name, role, password_hash, created_at, updated_at
-		FROM users WHERE id = $1`,
-		id,
-	).Scan(&user.ID, &user.Email, &user.FullName, &user.Role, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
-	return user, err
+	return scanUser(r.pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
+// List returns a page of users ordered by name and the total number matching the filter.
+func (r *UserRepository) List(ctx context.Context, filter domain.UserFilter) ([]domain.User, int, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+userColumns+`, COUNT(*) OVER ()
+		FROM users
+		WHERE ($1 = '' OR email ILIKE '%' || $1 || '%' OR full_name ILIKE '%' || $1 || '%')
+			AND ($2 = '' OR role::text = $2)
+			AND ($3::boolean IS NULL OR is_active = $3)
+		ORDER BY full_name, email
+		LIMIT $4 OFFSET $5`,
+		escapeLike(filter.Query), filter.Role, filter.Active, filter.Limit, filter.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var (
+		users []domain.User
+		total int
+	)
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Email, &user.FullName, &user.Role, &user.PasswordHash, &user.IsActive,
+			&user.CreatedAt, &user.UpdatedAt, &total); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// UpdateAccount changes the role and activity flag; nil fields are kept.
+func (r *UserRepository) UpdateAccount(ctx context.Context, id string, update domain.UserAccountUpdate) (domain.User, error) {
+	return scanUser(r.pool.QueryRow(ctx, `
+		UPDATE users
+		SET role = COALESCE($2::user_role, role), is_active = COALESCE($3, is_active), updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id, update.Role, update.IsActive,
+	))
 }
 
 func (r *UserRepository) UpdatePassword(ctx context.Context, id, passwordHash string) error {
@@ -48,13 +91,12 @@ func (r *UserRepository) UpdatePassword(ctx context.Context, id, passwordHash st
 }
 
 func (r *UserRepository) Create(ctx context.Context, email, fullName, role, passwordHash string) (domain.User, error) {
-	var user domain.User
-	err := r.pool.QueryRow(ctx, `
+	user, err := scanUser(r.pool.QueryRow(ctx, `
 		INSERT INTO users (email, full_name, role, password_hash)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id, email, full_name, role, password_hash, created_at, updated_at`,
+		RETURNING `+userColumns,
 		email, fullName, role, passwordHash,
-	).Scan(&user.ID, &user.Email, &user.FullName, &user.Role, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	))
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
@@ -64,3 +106,53 @@ func (r *UserRepository) Create(ctx context.Context, email, fullName, role, pass
 	}
 	return user, nil
 }
+
+// CreatePasswordReset stores a reset token hash unless the user requested one
+// less than throttle ago, and reports whether it was stored.
+func (r *UserRepository) CreatePasswordReset(ctx context.Context, userID, tokenHash string, expiresAt time.Time, throttle time.Duration) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM password_reset_tokens
+			WHERE user_id = $1 AND created_at > $4
+		)`,
+		userID, tokenHash, expiresAt, time.Now().Add(-throttle),
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ResetPassword consumes the token and sets the password in one statement, so
+// a token cannot be used twice. Other unused tokens of the user are voided too.
+// It returns the user id or domain.ErrResetTokenInvalid.
+func (r *UserRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (string, error) {
+	var userID string
+	err := r.pool.QueryRow(ctx, `
+		WITH consumed AS (
+			UPDATE password_reset_tokens SET used_at = NOW()
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+			RETURNING user_id
+		), voided AS (
+			UPDATE password_reset_tokens t SET used_at = NOW()
+			FROM consumed
+			WHERE t.user_id = consumed.user_id AND t.used_at IS NULL AND t.token_hash <> $1
+		)
+		UPDATE users u SET password_hash = $2, updated_at = NOW()
+		FROM consumed
+		WHERE u.id = consumed.user_id
+		RETURNING u.id`,
+		tokenHash, passwordHash,
+	).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", domain.ErrResetTokenInvalid
+	}
+	return userID, err
+}
+
+// escapeLike makes user input match literally inside a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"defect-tracker/internal/domain"
+)
+
+// WorkflowRepository stores workflows with their statuses and transitions.
+type WorkflowRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWorkflowRepository(pool *pgxpool.Pool) *WorkflowRepository {
+	return &WorkflowRepository{pool: pool}
+}
+
+func (r *WorkflowRepository) db(ctx context.Context) querier {
+	return conn(ctx, r.pool)
+}
+
+func (r *WorkflowRepository) List(ctx context.Context) ([]domain.Workflow, error) {
+	rows, err := r.db(ctx).Query(ctx, `
+		SELECT id
+		FROM workflows
+		ORDER BY is_default DESC, name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	workflows := make([]domain.Workflow, 0, len(ids))
+	for _, id := range ids {
+		workflow, err := r.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, workflow)
+	}
+	return workflows, nil
+}
+
+func (r *WorkflowRepository) GetByID(ctx context.Context, id string) (domain.Workflow, error) {
+	var workflow domain.Workflow
+	err := r.db(ctx).QueryRow(ctx, `
+		SELECT id, name, is_default, COALESCE(created_by::text, ''), created_at
+		FROM workflows WHERE id = $1`,
+		id,
+	).Scan(&workflow.ID, &workflow.Name, &workflow.IsDefault, &workflow.CreatedBy, &workflow.CreatedAt)
+	if err != nil {
+		return domain.Workflow{}, err
+	}
+
+	statusRows, err := r.db(ctx).Query(ctx, `
+		SELECT code, name, is_initial, is_final, position
+		FROM workflow_statuses
+		WHERE workflow_id = $1
+		ORDER BY position ASC, code ASC`,
+		id,
+	)
+	if err != nil {
+		return domain.Workflow{}, err
+	}
+	for statusRows.Next() {
+		var status domain.WorkflowStatus
+		if err := statusRows.Scan(&status.Code, &status.Name, &status.IsInitial, &status.IsFinal, &status.Position); err != nil {
+			statusRows.Close()
+			return domain.Workflow{}, err
+		}
+		workflow.Statuses = append(workflow.Statuses, status)
+	}
+	statusRows.Close()
+	if err := statusRows.Err(); err != nil {
+		return domain.Workflow{}, err
+	}
+
+	transitionRows, err := r.db(ctx).Query(ctx, `
+		SELECT from_status, to_status, allowed_roles, required_fields
+		FROM workflow_transitions
+		WHERE workflow_id = $1
+		ORDER BY from_status ASC, to_status ASC`,
+		id,
+	)
+	if err != nil {
+		return domain.Workflow{}, err
+	}
+	defer transitionRows.Close()
+	for transitionRows.Next() {
+		var transition domain.WorkflowTransition
+		if err := transitionRows.Scan(&transition.From, &transition.To, &transition.AllowedRoles, &transition.RequiredFields); err != nil {
+			return domain.Workflow{}, err
+		}
+		workflow.Transitions = append(workflow.Transitions, transition)
+	}
+	return workflow, transitionRows.Err()
+}
+
+// GetByProject returns the workflow assigned to the project or the default one.
+func (r *WorkflowRepository) GetByProject(ctx context.Context, projectID string) (domain.Workflow, error) {
+	var id string
+	err := r.db(ctx).QueryRow(ctx, `
+		SELECT COALESCE(p.workflow_id, (SELECT id FROM workflows WHERE is_default))
+		FROM projects p
+		WHERE p.id = $1`,
+		projectID,
+	).Scan(&id)
+	if err != nil {
+		return domain.Workflow{}, err
+	}
+	return r.GetByID(ctx, id)
+}
+
+func (r *WorkflowRepository) Create(ctx context.Context, payload domain.WorkflowCreate) (domain.Workflow, error) {
+	var workflowID string
+	err := r.db(ctx).QueryRow(ctx, `
+		INSERT INTO workflows (name, created_by)
+		VALUES ($1, $2)
+		RETURNING id`,
+		payload.Name,
+		nullIfEmpty(payload.CreatedBy),
+	).Scan(&workflowID)
+	if err != nil {
+		return domain.Workflow{}, err
+	}
+
+	for _, status := range payload.Statuses {
+		if _, err := r.db(ctx).Exec(ctx, `
+			INSERT INTO workflow_statuses (workflow_id, code, name, is_initial, is_final, position)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			workflowID, status.Code, status.Name, status.IsInitial, status.IsFinal, status.Position,
+		); err != nil {
+			return domain.Workflow{}, err
+		}
+	}
+
+	for _, transition := range payload.Transitions {
+		if _, err := r.db(ctx).Exec(ctx, `
+			INSERT INTO workflow_transitions (workflow_id, from_status, to_status, allowed_roles, required_fields)
+			VALUES ($1, $2, $3, $4, $5)`,
+			workflowID, transition.From, transition.To, nonNilStrings(transition.AllowedRoles), nonNilStrings(transition.RequiredFields),
+		); err != nil {
+			return domain.Workflow{}, err
+		}
+	}
+
+	return r.GetByID(ctx, workflowID)
+}
+
+// AssignToProject switches the project workflow, refusing when existing
+// defects of the project are in statuses unknown to the new workflow.
+func (r *WorkflowRepository) AssignToProject(ctx context.Context, projectID, workflowID string) error {
+	var orphaned bool
+	err := r.db(ctx).QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM defects d
+			WHERE d.project_id = $1
+				AND d.status NOT IN (SELECT code FROM workflow_statuses WHERE workflow_id = $2)
+		)`,
+		projectID, workflowID,
+	).Scan(&orphaned)
+	if err != nil {
+		return err
+	}
+	if orphaned {
+		return domain.ErrWorkflowMismatch
+	}
+
+	_, err = r.db(ctx).Exec(ctx, `
+		UPDATE projects SET workflow_id = $1, updated_at = NOW()
+		WHERE id = $2`,
+		workflowID, projectID,
+	)
+	return err
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,189 @@
+package blobgc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"defect-tracker/internal/pkg/storage"
+	"defect-tracker/internal/pkg/thumbnail"
+)
+
+// QuarantinePrefix is where quarantined blobs are moved; the collector never scans it.
+const QuarantinePrefix = "quarantine/"
+
+// Modes of handling orphaned blobs.
+const (
+	ModeQuarantine = "quarantine"
+	ModeDelete     = "delete"
+)
+
+// Repository returns keys referenced by attachments mapped to whether they have
+// thumbnails and drops blob records no attachment uses anymore.
+type Repository interface {
+	ReferencedStorageKeys(ctx context.Context) (map[string]bool, error)
+	PruneUnreferencedBlobs(ctx context.Context) (int64, error)
+}
+
+type Options struct {
+	// GracePeriod protects fresh blobs whose attachment rows may not be committed yet.
+	GracePeriod time.Duration
+	Mode        string
+	DryRun      bool
+}
+
+// Orphan is a blob without an attachment row.
+type Orphan struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Report summarises one reconciliation run.
+type Report struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Mode       string
+	DryRun     bool
+	// StaleBlobs counts blob records left by cascaded deletes; their files become orphans.
+	StaleBlobs  int64
+	Scanned     int
+	Referenced  int
+	TooYoung    int
+	Orphans     []Orphan
+	OrphanBytes int64
+	Removed     int
+	Failed      int
+}
+
+// Service reconciles blobs in the storage with defect_attachments.storage_key.
+type Service struct {
+	repo    Repository
+	storage storage.Provider
+	log     *zap.Logger
+}
+
+func NewService(repo Repository, storage storage.Provider, log *zap.Logger) *Service {
+	return &Service{repo: repo, storage: storage, log: log}
+}
+
+// Run lists all blobs and removes or quarantines those not referenced by any
+// attachment and older than the grace period. With DryRun nothing is changed.
+func (s *Service) Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.Mode != ModeDelete {
+		opts.Mode = ModeQuarantine
+	}
+	report := Report{StartedAt: time.Now(), Mode: opts.Mode, DryRun: opts.DryRun}
+
+	if !opts.DryRun {
+		// Must go first: a pruned record no longer lets new uploads reuse a file
+		// that is about to be collected below.
+		stale, err := s.repo.PruneUnreferencedBlobs(ctx)
+		if err != nil {
+			return report, fmt.Errorf("prune blob records: %w", err)
+		}
+		report.StaleBlobs = stale
+	}
+
+	referenced, err := s.repo.ReferencedStorageKeys(ctx)
+	if err != nil {
+		return report, fmt.Errorf("load referenced keys: %w", err)
+	}
+	cutoff := report.StartedAt.Add(-opts.GracePeriod)
+
+	// Orphans are collected first: changing the storage while listing it is not safe for every driver.
+	err = s.storage.List(ctx, "", func(info storage.ObjectInfo) error {
+		if strings.HasPrefix(info.Key, QuarantinePrefix) {
+			return nil
+		}
+		report.Scanned++
+		if isReferenced(info.Key, referenced) {
+			report.Referenced++
+			return nil
+		}
+		if info.ModTime.After(cutoff) {
+			report.TooYoung++
+			return nil
+		}
+		report.Orphans = append(report.Orphans, Orphan{Key: info.Key, Size: info.Size, ModTime: info.ModTime})
+		report.OrphanBytes += info.Size
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("list storage: %w", err)
+	}
+
+	if !opts.DryRun {
+		for _, orphan := range report.Orphans {
+			if err := s.dispose(ctx, orphan.Key, opts.Mode); err != nil {
+				report.Failed++
+				s.log.Warn("failed to dispose orphaned blob", zap.String("key", orphan.Key), zap.Error(err))
+				continue
+			}
+			report.Removed++
+		}
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// RunEvery runs the collector on a timer until ctx is cancelled.
+func (s *Service) RunEvery(ctx context.Context, interval time.Duration, opts Options) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Run(ctx, opts)
+			if err != nil {
+				s.log.Error("orphaned blob collection failed", zap.Error(err))
+				continue
+			}
+			s.log.Info("orphaned blob collection finished",
+				zap.String("mode", report.Mode),
+				zap.Bool("dryRun", report.DryRun),
+				zap.Int("scanned", report.Scanned),
+				zap.Int("orphans", len(report.Orphans)),
+				zap.Int64("orphanBytes", report.OrphanBytes),
+				zap.Int("removed", report.Removed),
+				zap.Int("failed", report.Failed),
+				zap.Int64("staleBlobs", report.StaleBlobs),
+			)
+		}
+	}
+}
+
+func (s *Service) dispose(ctx context.Context, key, mode string) error {
+	if mode == ModeQuarantine {
+		if err := s.copyTo(ctx, key, QuarantinePrefix+key); err != nil {
+			return err
+		}
+	}
+	return s.storage.Delete(ctx, key)
+}
+
+func (s *Service) copyTo(ctx context.Context, from, to string) error {
+	object, err := s.storage.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	_, err = s.storage.Put(ctx, to, object, object.Size, object.ContentType)
+	return err
+}
+
+// isReferenced treats thumbnails as referenced while their original is.
+func isReferenced(key string, referenced map[string]bool) bool {
+	if _, ok := referenced[key]; ok {
+		return true
+	}
+	if original, ok := thumbnail.OriginalKey(key); ok {
+		return referenced[original]
+	}
+	return false
+}
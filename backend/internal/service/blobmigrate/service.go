@@ -0,0 +1,228 @@
+package blobmigrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/storage"
+	"defect-tracker/internal/pkg/thumbnail"
+)
+
+// Statuses reported for every blob.
+const (
+	StatusCopied  = "copied"
+	StatusSkipped = "skipped"
+	StatusPlanned = "planned"
+	StatusMissing = "missing"
+	StatusFailed  = "failed"
+)
+
+// ErrChecksumMismatch means the copy differs from the source or the source
+// differs from the checksum recorded at upload.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Repository lists blobs referenced by live attachments and renames their keys.
+type Repository interface {
+	ListLiveBlobs(ctx context.Context) ([]domain.Blob, error)
+	RenameBlob(ctx context.Context, oldKey, newKey string) error
+}
+
+type Options struct {
+	// KeyPrefix, when set, is prepended to keys in the target storage and the
+	// attachment rows are updated accordingly.
+	KeyPrefix string
+	DryRun    bool
+}
+
+// Progress describes the outcome for one blob.
+type Progress struct {
+	Index  int
+	Total  int
+	Key    string
+	Bytes  int64
+	Status string
+	Err    error
+}
+
+// Report summarises a migration run.
+type Report struct {
+	Total   int
+	Copied  int
+	Skipped int
+	// Missing counts blobs absent from the source storage. They cannot be
+	// copied by any rerun, so they are reported but do not fail the run.
+	Missing int
+	Failed  int
+	Bytes   int64
+}
+
+// Service copies blobs between storage providers. A run can be interrupted and
+// started again: blobs already present and verified in the target are skipped.
+type Service struct {
+	repo Repository
+	log  *zap.Logger
+}
+
+func NewService(repo Repository, log *zap.Logger) *Service {
+	return &Service{repo: repo, log: log}
+}
+
+// Run copies every referenced blob with its thumbnails from one provider to
+// the other, verifying each copy by SHA-256. progress is called after each blob.
+func (s *Service) Run(ctx context.Context, from, to storage.Provider, opts Options, progress func(Progress)) (Report, error) {
+	blobs, err := s.repo.ListLiveBlobs(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("list blobs: %w", err)
+	}
+
+	report := Report{Total: len(blobs)}
+	for i, blob := range blobs {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		step := Progress{Index: i + 1, Total: len(blobs), Key: blob.StorageKey, Bytes: blob.SizeBytes}
+		step.Status, step.Err = s.migrate(ctx, from, to, blob, opts)
+		switch step.Status {
+		case StatusCopied:
+			report.Copied++
+			report.Bytes += blob.SizeBytes
+		case StatusSkipped, StatusPlanned:
+			report.Skipped++
+		case StatusMissing:
+			report.Missing++
+			s.log.Warn("blob is missing in the source storage", zap.String("key", blob.StorageKey))
+		default:
+			report.Failed++
+			s.log.Warn("failed to migrate blob", zap.String("key", blob.StorageKey), zap.Error(step.Err))
+		}
+		if progress != nil {
+			progress(step)
+		}
+	}
+	return report, nil
+}
+
+func (s *Service) migrate(ctx context.Context, from, to storage.Provider, blob domain.Blob, opts Options) (string, error) {
+	sourceKey, targetKey := blob.StorageKey, opts.KeyPrefix+blob.StorageKey
+	// A key that already carries the prefix was rewritten by an earlier run.
+	if opts.KeyPrefix != "" && strings.HasPrefix(blob.StorageKey, opts.KeyPrefix) {
+		sourceKey, targetKey = strings.TrimPrefix(blob.StorageKey, opts.KeyPrefix), blob.StorageKey
+	}
+
+	done, err := alreadyCopied(ctx, from, to, sourceKey, targetKey, blob.Checksum)
+	if err != nil {
+		return StatusFailed, err
+	}
+	if opts.DryRun {
+		if done {
+			return StatusSkipped, nil
+		}
+		return StatusPlanned, nil
+	}
+
+	status := StatusSkipped
+	if !done {
+		if err := copyVerified(ctx, from, to, sourceKey, targetKey, blob.Checksum); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return StatusMissing, err
+			}
+			return StatusFailed, err
+		}
+		status = StatusCopied
+	}
+
+	// Previews can be rendered again, so a missing one does not fail the blob.
+	if blob.HasThumbnails {
+		for _, size := range thumbnail.Sizes {
+			source, target := thumbnail.Key(sourceKey, size.Name), thumbnail.Key(targetKey, size.Name)
+			copied, err := alreadyCopied(ctx, from, to, source, target, "")
+			if err == nil && !copied {
+				err = copyVerified(ctx, from, to, source, target, "")
+			}
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return StatusFailed, fmt.Errorf("thumbnail %s: %w", size.Name, err)
+			}
+		}
+	}
+
+	if targetKey != blob.StorageKey {
+		if err := s.repo.RenameBlob(ctx, blob.StorageKey, targetKey); err != nil {
+			return StatusFailed, fmt.Errorf("rewrite key: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// alreadyCopied reports whether the target holds the same content as the
+// source. Hashing both sides costs a read but keeps reruns trustworthy.
+func alreadyCopied(ctx context.Context, from, to storage.Provider, sourceKey, targetKey, checksum string) (bool, error) {
+	targetSum, _, err := hashObject(ctx, to, targetKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if checksum == "" {
+		checksum, _, err = hashObject(ctx, from, sourceKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return targetSum == checksum, nil
+}
+
+// copyVerified streams the blob into the target while hashing it and then
+// reads the copy back to compare checksums.
+func copyVerified(ctx context.Context, from, to storage.Provider, sourceKey, targetKey, checksum string) error {
+	object, err := from.Open(ctx, sourceKey)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	digest := sha256.New()
+	written, err := to.Put(ctx, targetKey, io.TeeReader(object, digest), object.Size, object.ContentType)
+	if err != nil {
+		return err
+	}
+	sourceSum := hex.EncodeToString(digest.Sum(nil))
+	if checksum != "" && sourceSum != checksum {
+		return fmt.Errorf("source differs from the checksum recorded at upload: %w", ErrChecksumMismatch)
+	}
+
+	targetSum, targetSize, err := hashObject(ctx, to, targetKey)
+	if err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	if targetSum != sourceSum || targetSize != written {
+		return fmt.Errorf("copy differs from the source: %w", ErrChecksumMismatch)
+	}
+	return nil
+}
+
+func hashObject(ctx context.Context, provider storage.Provider, key string) (string, int64, error) {
+	object, err := provider.Open(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer object.Close()
+
+	digest := sha256.New()
+	n, err := io.Copy(digest, object)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), n, nil
+}
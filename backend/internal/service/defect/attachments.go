@@ -0,0 +1,186 @@
+package defect
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"defect-tracker/internal/domain"
+)
+
+// AttachmentLimits bounds attachment uploads in bytes. Zero disables a limit.
+type AttachmentLimits struct {
+	MaxFileSize int64
+	// MaxResumableFileSize applies to resumable uploads, which exist for
+	// files too large for a single request such as video walkthroughs.
+	MaxResumableFileSize int64
+	MaxDefectSize        int64
+}
+
+// SniffLength is the number of leading bytes DetectContentType needs to recognise a file.
+const SniffLength = 3072
+
+// allowedAttachmentTypes lists accepted types: site photos and video walkthroughs,
+// drawings, PDF and office documents.
+var allowedAttachmentTypes = map[string]struct{}{
+	"image/jpeg":                    {},
+	"image/png":                     {},
+	"image/webp":                    {},
+	"image/heic":                    {},
+	"image/heif":                    {},
+	"image/tiff":                    {},
+	"image/vnd.dwg":                 {},
+	"video/mp4":                     {},
+	"video/quicktime":               {},
+	"application/pdf":               {},
+	"application/msword":            {},
+	"application/vnd.ms-excel":      {},
+	"application/vnd.ms-powerpoint": {},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   {},
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         {},
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": {},
+	"application/vnd.oasis.opendocument.text":                                   {},
+	"application/vnd.oasis.opendocument.spreadsheet":                            {},
+}
+
+// DetectContentType identifies the file by its leading bytes, ignoring the type
+// declared by the client, and rejects types outside the allow-list.
+func DetectContentType(head []byte) (string, error) {
+	contentType, _, _ := strings.Cut(mimetype.Detect(head).String(), ";")
+	if !isAllowedAttachmentType(contentType) {
+		return "", domain.ErrUnsupportedAttachmentType
+	}
+	return contentType, nil
+}
+
+func isAllowedAttachmentType(contentType string) bool {
+	_, ok := allowedAttachmentTypes[contentType]
+	return ok
+}
+
+// MaxUploadSize returns the per-file limit so transport can cap request bodies.
+func (s *Service) MaxUploadSize() int64 {
+	return s.limits.MaxFileSize
+}
+
+// CheckUploadSize verifies a file of the given size fits the per-file limit
+// and the remaining quota of the defect.
+func (s *Service) CheckUploadSize(ctx context.Context, defectID string, size int64) error {
+	if s.limits.MaxFileSize > 0 && size > s.limits.MaxFileSize {
+		return domain.ErrFileTooLarge
+	}
+	return s.checkDefectQuota(ctx, defectID, size)
+}
+
+// CheckResumableUploadSize is CheckUploadSize for resumable uploads, which
+// have their own, larger per-file limit.
+func (s *Service) CheckResumableUploadSize(ctx context.Context, defectID string, size int64) error {
+	if s.limits.MaxResumableFileSize > 0 && size > s.limits.MaxResumableFileSize {
+		return domain.ErrFileTooLarge
+	}
+	return s.checkDefectQuota(ctx, defectID, size)
+}
+
+func (s *Service) checkDefectQuota(ctx context.Context, defectID string, size int64) error {
+	if s.limits.MaxDefectSize <= 0 {
+		return nil
+	}
+	used, err := s.repo.AttachmentsSize(ctx, defectID)
+	if err != nil {
+		return err
+	}
+	if used+size > s.limits.MaxDefectSize {
+		return domain.ErrAttachmentQuotaExceeded
+	}
+	return nil
+}
+
+// historyFieldAttachment is the defect_history field for replaced and deleted attachments.
+const historyFieldAttachment = "attachment"
+
+// ListAttachmentVersions returns every version of the attachment, newest first.
+func (s *Service) ListAttachmentVersions(ctx context.Context, defectID, attachmentID string, actor domain.User) ([]domain.Attachment, error) {
+	if _, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleObserver); err != nil {
+		return nil, err
+	}
+	attachment, err := s.repo.GetAttachment(ctx, defectID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListAttachmentVersions(ctx, defectID, attachment.RootID())
+}
+
+// DeleteAttachment soft-deletes the attachment with all its versions and records
+// it in the defect history. The returned blobs are no longer referenced by any
+// attachment and must be purged by the caller.
+func (s *Service) DeleteAttachment(ctx context.Context, defectID, attachmentID string, actor domain.User) ([]domain.Blob, error) {
+	projectID, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleEngineer)
+	if err != nil {
+		return nil, err
+	}
+
+	var released []domain.Blob
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockForUpdate(ctx, defectID); err != nil {
+			return err
+		}
+		attachment, err := s.repo.GetAttachment(ctx, defectID, attachmentID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkAttachmentOwner(ctx, projectID, actor, attachment); err != nil {
+			return err
+		}
+		deleted, err := s.repo.DeleteAttachment(ctx, defectID, attachment.RootID(), actor.ID)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(deleted))
+		for _, att := range deleted {
+			keys = append(keys, att.StorageKey)
+		}
+		if released, err = s.repo.ReleaseBlobs(ctx, keys); err != nil {
+			return err
+		}
+		return s.repo.AddHistory(ctx, defectID, actor.ID, historyFieldAttachment, attachment.Filename, "")
+	})
+	return released, err
+}
+
+// FindBlob returns the stored file with the given SHA-256, letting uploads of
+// a duplicate skip work such as rendering thumbnails.
+func (s *Service) FindBlob(ctx context.Context, checksum string) (domain.Blob, error) {
+	return s.repo.FindBlob(ctx, checksum)
+}
+
+// checkAttachmentOwner allows changing an attachment to its author and to project managers.
+func (s *Service) checkAttachmentOwner(ctx context.Context, projectID string, actor domain.User, attachment domain.Attachment) error {
+	if attachment.UploadedBy == actor.ID {
+		return nil
+	}
+	role, err := s.access.MemberRole(ctx, projectID, actor.ID)
+	if err != nil {
+		return err
+	}
+	if !domain.ProjectRoleAtLeast(role, domain.ProjectRoleManager) {
+		return domain.ErrAttachmentForbidden
+	}
+	return nil
+}
+
+// ExportAttachments returns current attachments of the defect for an archive.
+func (s *Service) ExportAttachments(ctx context.Context, defectID string, actor domain.User) ([]domain.ExportedAttachment, error) {
+	if _, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleObserver); err != nil {
+		return nil, err
+	}
+	return s.repo.ListExportAttachments(ctx, "", defectID)
+}
+
+// ExportProjectAttachments returns current attachments of every defect of the project.
+func (s *Service) ExportProjectAttachments(ctx context.Context, projectID string, actor domain.User) ([]domain.ExportedAttachment, error) {
+	if _, err := s.authorizeProject(ctx, projectID, actor, domain.ProjectRoleObserver); err != nil {
+		return nil, err
+	}
+	return s.repo.ListExportAttachments(ctx, projectID, "")
+}
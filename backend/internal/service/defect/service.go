@@ -2,147 +2,675 @@ package defect
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"defect-tracker/internal/domain"
 )
 
 type Repository interface {
-	List(ctx context.Context, filter domain.DefectFilter) ([]domain.DefectListItem, error)
+	List(ctx context.Context, filter domain.DefectFilter) (domain.DefectPage, error)
 	Create(ctx context.Context, payload domain.DefectCreate) (domain.Defect, error)
 	GetByID(ctx context.Context, id string) (domain.Defect, error)
-	UpdateStatus(ctx context.Context, id, status, actorID string) error
+	GetProjectID(ctx context.Context, id string) (string, error)
+	Update(ctx context.Context, id string, payload domain.DefectUpdate) error
+	LockForUpdate(ctx context.Context, id string) error
+	UpdateStatus(ctx context.Context, id, fromStatus, toStatus, actorID string) error
+	IncrementReopenCount(ctx context.Context, id string) error
 	AddHistory(ctx context.Context, defectID, actorID, field, oldValue, newValue string) error
+	AddStatusHistory(ctx context.Context, defectID, actorID, oldStatus, newStatus, reason string) error
+	ListHistory(ctx context.Context, defectID string, limit, offset int) ([]domain.HistoryEntry, int, error)
 	AddComment(ctx context.Context, payload domain.CommentCreate) (domain.Comment, error)
 	ListComments(ctx context.Context, defectID string) ([]domain.Comment, error)
 	AddAttachment(ctx context.Context, payload domain.AttachmentCreate) (domain.Attachment, error)
 	ListAttachments(ctx context.Context, defectID string) ([]domain.Attachment, error)
+	AttachmentsSize(ctx context.Context, defectID string) (int64, error)
 	GetAttachment(ctx context.Context, defectID, attachmentID string) (domain.Attachment, error)
+	ListAttachmentVersions(ctx context.Context, defectID, rootID string) ([]domain.Attachment, error)
+	ListExportAttachments(ctx context.Context, projectID, defectID string) ([]domain.ExportedAttachment, error)
+	SupersedeAttachment(ctx context.Context, defectID, attachmentID string) error
+	DeleteAttachment(ctx context.Context, defectID, rootID, actorID string) ([]domain.Attachment, error)
+	FindBlob(ctx context.Context, checksum string) (domain.Blob, error)
+	AcquireBlob(ctx context.Context, blob domain.Blob) (domain.Blob, error)
+	ReleaseBlobs(ctx context.Context, storageKeys []string) ([]domain.Blob, error)
 }
 
-// Service handles domain-level logic for defects.
+// Transactor runs fn atomically; repository calls made with the ctx passed to fn share the transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WorkflowProvider resolves the workflow that governs defects of a project.
+type WorkflowProvider interface {
+	ForProject(ctx context.Context, projectID string) (domain.Workflow, error)
+}
+
+// AccessChecker resolves per-project roles of users and project state.
+type AccessChecker interface {
+	MemberRole(ctx context.Context, projectID, userID string) (string, error)
+	IsArchived(ctx context.Context, projectID string) (bool, error)
+}
+
+// Service handles domain-level logic for defects. Every operation is limited
+// to projects the actor is a member of and checked against the per-project role.
 type Service struct {
-	repo Repository
+	repo      Repository
+	tx        Transactor
+	workflows WorkflowProvider
+	access    AccessChecker
+	limits    AttachmentLimits
 }
 
+// Defect validation errors carry messages for the client.
+var (
+	ErrTitleRequired     = errors.New("название дефекта обязательно")
+	ErrInvalidPriority   = errors.New("некорректный приоритет")
+	ErrInvalidSeverity   = errors.New("некорректная критичность")
+	ErrAssigneeNotMember = errors.New("исполнитель не участвует в проекте")
+)
+
 var (
-	allowedStatuses = map[string]struct{}{
-		"NEW":         {},
-		"IN_PROGRESS": {},
-		"IN_REVIEW":   {},
-		"CLOSED":      {},
-		"CANCELED":    {},
-	}
 	allowedPriorities = map[string]struct{}{
 		"LOW":      {},
 		"MEDIUM":   {},
 		"HIGH":     {},
 		"CRITICAL": {},
 	}
+	allowedSorts = map[string]struct{}{
+		domain.SortCreatedAt: {},
+		domain.SortUpdatedAt: {},
+		domain.SortDueDate:   {},
+		domain.SortPriority:  {},
+		domain.SortStatus:    {},
+		domain.SortRelevance: {},
+	}
+	allowedSeverities = map[string]struct{}{
+		"MINOR":    {},
+		"MAJOR":    {},
+		"CRITICAL": {},
+	}
 )
 
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo Repository, tx Transactor, workflows WorkflowProvider, access AccessChecker, limits AttachmentLimits) *Service {
+	return &Service{repo: repo, tx: tx, workflows: workflows, access: access, limits: limits}
 }
 
-func (s *Service) List(ctx context.Context, filter domain.DefectFilter) ([]domain.DefectListItem, error) {
+// List returns a page of defects from the actor's projects. Unknown sort keys
+// and relevance ordering without a search query fall back to the newest-first order.
+func (s *Service) List(ctx context.Context, actor domain.User, filter domain.DefectFilter) (domain.DefectPage, error) {
 	if filter.Limit <= 0 || filter.Limit > 100 {
 		filter.Limit = 20
 	}
 
-	filter.Status = normalizeEnum(filter.Status, allowedStatuses)
-	filter.Priority = normalizeEnum(filter.Priority, allowedPriorities)
+	filter.MemberID = actor.ID
+
+	filter.Statuses = normalizeValues(filter.Statuses, normalizeStatus)
+	filter.Priorities = normalizeValues(filter.Priorities, func(value string) string {
+		return normalizeEnum(value, allowedPriorities)
+	})
+	filter.Severities = normalizeValues(filter.Severities, func(value string) string {
+		return normalizeEnum(value, allowedSeverities)
+	})
+	filter.Projects = normalizeValues(filter.Projects, strings.TrimSpace)
+	filter.Assignees = normalizeValues(filter.Assignees, func(value string) string {
+		return resolveUserRef(value, actor, true)
+	})
+	filter.Creators = normalizeValues(filter.Creators, func(value string) string {
+		return resolveUserRef(value, actor, false)
+	})
+	// Ids are cast to uuid in SQL; a malformed one must be a client error.
+	for _, ids := range [][]string{filter.Projects, filter.Assignees, filter.Creators} {
+		for _, id := range ids {
+			if id != domain.AssigneeNone && !isUUID(id) {
+				return domain.DefectPage{}, domain.ErrInvalidFilterID
+			}
+		}
+	}
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	if _, ok := allowedSorts[filter.Sort]; !ok || (filter.Sort == domain.SortRelevance && filter.Query == "") {
+		filter.Sort = domain.SortCreatedAt
+		filter.SortDesc = true
+	}
 
 	return s.repo.List(ctx, filter)
 }
 
-func (s *Service) Create(ctx context.Context, payload domain.DefectCreate) (domain.Defect, error) {
+func (s *Service) Create(ctx context.Context, actor domain.User, payload domain.DefectCreate) (domain.Defect, error) {
+	if _, err := s.authorizeProject(ctx, payload.ProjectID, actor, domain.ProjectRoleManager); err != nil {
+		return domain.Defect{}, err
+	}
+	if err := s.checkAssignee(ctx, payload.ProjectID, payload.AssigneeID); err != nil {
+		return domain.Defect{}, err
+	}
+
 	payload.Priority = normalizeEnum(payload.Priority, allowedPriorities)
-	payload.Severity = normalizeEnum(payload.Severity, allowedPriorities)
+	payload.Severity = normalizeEnum(payload.Severity, allowedSeverities)
+
+	workflow, err := s.workflows.ForProject(ctx, payload.ProjectID)
+	if err != nil {
+		return domain.Defect{}, fmt.Errorf("проект не найден")
+	}
+	payload.Status = workflow.InitialStatus()
+	if payload.Status == "" {
+		return domain.Defect{}, fmt.Errorf("в workflow проекта не задан начальный статус")
+	}
+
 	return s.repo.Create(ctx, payload)
 }
 
-func (s *Service) Get(ctx context.Context, id string) (domain.Defect, error) {
+func (s *Service) Get(ctx context.Context, id string, actor domain.User) (domain.Defect, error) {
+	if _, err := s.authorize(ctx, id, actor, domain.ProjectRoleObserver); err != nil {
+		return domain.Defect{}, err
+	}
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *Service) AddComment(ctx context.Context, payload domain.CommentCreate) (domain.Comment, error) {
+// GetWithTimeline loads the defect with its full history and builds a merged,
+// chronologically ordered timeline of history, comments and attachments.
+func (s *Service) GetWithTimeline(ctx context.Context, id string, actor domain.User) (domain.Defect, []domain.TimelineEvent, error) {
+	if _, err := s.authorize(ctx, id, actor, domain.ProjectRoleObserver); err != nil {
+		return domain.Defect{}, nil, err
+	}
+
+	defect, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Defect{}, nil, err
+	}
+
+	history, _, err := s.repo.ListHistory(ctx, id, 0, 0)
+	if err != nil {
+		return domain.Defect{}, nil, err
+	}
+	defect.History = history
+
+	return defect, buildTimeline(defect), nil
+}
+
+func (s *Service) ListHistory(ctx context.Context, defectID string, actor domain.User, limit, offset int) ([]domain.HistoryEntry, int, error) {
+	if _, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleObserver); err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.repo.ListHistory(ctx, defectID, limit, offset)
+}
+
+// Update applies a partial update and records one history entry per changed field.
+func (s *Service) Update(ctx context.Context, defectID string, actor domain.User, payload domain.DefectUpdate) (domain.Defect, error) {
+	projectID, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleManager)
+	if err != nil {
+		return domain.Defect{}, err
+	}
+	if payload.AssigneeID != nil {
+		if err := s.checkAssignee(ctx, projectID, *payload.AssigneeID); err != nil {
+			return domain.Defect{}, err
+		}
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockForUpdate(ctx, defectID); err != nil {
+			return err
+		}
+		defect, err := s.repo.GetByID(ctx, defectID)
+		if err != nil {
+			return err
+		}
+
+		changes, err := collectChanges(defect, &payload)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		payload.UpdatedBy = actor.ID
+		if err := s.repo.Update(ctx, defectID, payload); err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if err := s.repo.AddHistory(ctx, defectID, actor.ID, change.field, change.oldValue, change.newValue); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.Defect{}, err
+	}
+
+	return s.repo.GetByID(ctx, defectID)
+}
+
+func (s *Service) AddComment(ctx context.Context, actor domain.User, payload domain.CommentCreate) (domain.Comment, error) {
+	if _, err := s.authorize(ctx, payload.DefectID, actor, domain.ProjectRoleEngineer); err != nil {
+		return domain.Comment{}, err
+	}
 	if strings.TrimSpace(payload.Body) == "" {
 		return domain.Comment{}, fmt.Errorf("comment body is empty")
 	}
 	return s.repo.AddComment(ctx, payload)
 }
 
-func (s *Service) ListComments(ctx context.Context, defectID string) ([]domain.Comment, error) {
+func (s *Service) ListComments(ctx context.Context, defectID string, actor domain.User) ([]domain.Comment, error) {
+	if _, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleObserver); err != nil {
+		return nil, err
+	}
 	return s.repo.ListComments(ctx, defectID)
 }
 
-func (s *Service) AddAttachment(ctx context.Context, payload domain.AttachmentCreate) (domain.Attachment, error) {
+// AuthorizeUpload lets the transport layer check access before the file is written to storage.
+func (s *Service) AuthorizeUpload(ctx context.Context, defectID string, actor domain.User) error {
+	_, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleEngineer)
+	return err
+}
+
+// AddAttachment stores the attachment row. With ReplacesID set it becomes a new
+// version of an existing attachment, which only its author or a project manager may do.
+// The returned StorageKey differs from the payload when the content was already stored.
+func (s *Service) AddAttachment(ctx context.Context, actor domain.User, payload domain.AttachmentCreate) (domain.Attachment, error) {
+	projectID, err := s.authorize(ctx, payload.DefectID, actor, domain.ProjectRoleEngineer)
+	if err != nil {
+		return domain.Attachment{}, err
+	}
 	if payload.SizeBytes <= 0 {
 		return domain.Attachment{}, fmt.Errorf("attachment is empty")
 	}
-	return s.repo.AddAttachment(ctx, payload)
+	if !isAllowedAttachmentType(payload.ContentType) {
+		return domain.Attachment{}, domain.ErrUnsupportedAttachmentType
+	}
+	payload.UploadedBy = actor.ID
+
+	// The quota is re-checked under the defect lock so parallel uploads cannot
+	// overshoot it. Per-file limits depend on the upload protocol and were
+	// checked before the content was accepted.
+	var attachment domain.Attachment
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockForUpdate(ctx, payload.DefectID); err != nil {
+			return err
+		}
+		if err := s.checkDefectQuota(ctx, payload.DefectID, payload.SizeBytes); err != nil {
+			return err
+		}
+
+		if payload.ReplacesID != "" {
+			previous, err := s.repo.GetAttachment(ctx, payload.DefectID, payload.ReplacesID)
+			if err != nil {
+				return err
+			}
+			if err := s.checkAttachmentOwner(ctx, projectID, actor, previous); err != nil {
+				return err
+			}
+			if err := s.repo.SupersedeAttachment(ctx, payload.DefectID, previous.ID); err != nil {
+				return err
+			}
+			if err := s.repo.AddHistory(ctx, payload.DefectID, actor.ID, historyFieldAttachment, previous.Filename, payload.Filename); err != nil {
+				return err
+			}
+		}
+
+		// A file with the same content is stored once; the row then points to
+		// the existing blob and the caller purges the freshly saved one.
+		blob, err := s.repo.AcquireBlob(ctx, domain.Blob{
+			StorageKey:    payload.StorageKey,
+			Checksum:      payload.Checksum,
+			SizeBytes:     payload.SizeBytes,
+			HasThumbnails: payload.HasThumbnails,
+		})
+		if err != nil {
+			return err
+		}
+		payload.StorageKey, payload.HasThumbnails = blob.StorageKey, blob.HasThumbnails
+
+		attachment, err = s.repo.AddAttachment(ctx, payload)
+		return err
+	})
+	return attachment, err
 }
 
-func (s *Service) ListAttachments(ctx context.Context, defectID string) ([]domain.Attachment, error) {
+func (s *Service) ListAttachments(ctx context.Context, defectID string, actor domain.User) ([]domain.Attachment, error) {
+	if _, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleObserver); err != nil {
+		return nil, err
+	}
 	return s.repo.ListAttachments(ctx, defectID)
 }
 
-func (s *Service) GetAttachment(ctx context.Context, defectID, attachmentID string) (domain.Attachment, error) {
+func (s *Service) GetAttachment(ctx context.Context, defectID, attachmentID string, actor domain.User) (domain.Attachment, error) {
+	if _, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleObserver); err != nil {
+		return domain.Attachment{}, err
+	}
 	return s.repo.GetAttachment(ctx, defectID, attachmentID)
 }
 
-func (s *Service) UpdateStatus(ctx context.Context, defectID string, actor domain.User, newStatus string) (domain.Defect, error) {
-	nextStatus := normalizeEnum(newStatus, allowedStatuses)
+// UpdateStatus performs the transition allowed by the project workflow under a
+// row lock so the check, the update and the history entry are applied atomically.
+// When ExpectedStatus is set and differs from the stored one, domain.ErrConflict is returned.
+func (s *Service) UpdateStatus(ctx context.Context, defectID string, actor domain.User, change domain.StatusChange) (domain.Defect, error) {
+	nextStatus := normalizeStatus(change.Status)
 	if nextStatus == "" {
 		return domain.Defect{}, fmt.Errorf("некорректный статус")
 	}
+	expectedStatus := normalizeStatus(change.ExpectedStatus)
+	comment := strings.TrimSpace(change.Comment)
+	reason := strings.TrimSpace(change.Reason)
 
-	defect, err := s.repo.GetByID(ctx, defectID)
+	projectID, err := s.authorize(ctx, defectID, actor, domain.ProjectRoleEngineer)
+	if err != nil {
+		return domain.Defect{}, err
+	}
+	projectRole, err := s.access.MemberRole(ctx, projectID, actor.ID)
+	if err != nil {
+		return domain.Defect{}, err
+	}
+
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockForUpdate(ctx, defectID); err != nil {
+			return err
+		}
+		defect, err := s.repo.GetByID(ctx, defectID)
+		if err != nil {
+			return err
+		}
+
+		if expectedStatus != "" && defect.Status != expectedStatus {
+			return domain.ErrConflict
+		}
+
+		if defect.Status == nextStatus {
+			return nil
+		}
+
+		workflow, err := s.workflows.ForProject(ctx, defect.ProjectID)
+		if err != nil {
+			return err
+		}
+		if !workflow.HasStatus(nextStatus) {
+			return fmt.Errorf("некорректный статус")
+		}
+
+		transition, ok := workflow.Transition(defect.Status, nextStatus)
+		if !ok {
+			return fmt.Errorf("переход %s -> %s запрещён", defect.Status, nextStatus)
+		}
+		if !transition.AllowsRole(projectRole) {
+			return fmt.Errorf("переход %s -> %s недоступен для вашей роли", defect.Status, nextStatus)
+		}
+		if err := checkRequiredFields(transition, defect, comment, reason); err != nil {
+			return err
+		}
+
+		if err := s.repo.UpdateStatus(ctx, defectID, defect.Status, nextStatus, actor.ID); err != nil {
+			return err
+		}
+		if err := s.repo.AddStatusHistory(ctx, defectID, actor.ID, defect.Status, nextStatus, reason); err != nil {
+			return err
+		}
+		if workflow.IsReopen(defect.Status, nextStatus) {
+			if err := s.repo.IncrementReopenCount(ctx, defectID); err != nil {
+				return err
+			}
+		}
+
+		if comment != "" {
+			_, err := s.repo.AddComment(ctx, domain.CommentCreate{
+				DefectID: defectID,
+				AuthorID: actor.ID,
+				Body:     comment,
+			})
+			return err
+		}
+		return nil
+	})
 	if err != nil {
 		return domain.Defect{}, err
 	}
 
-	if defect.Status == nextStatus {
-		return defect, nil
+	return s.repo.GetByID(ctx, defectID)
+}
+
+// authorize checks that the actor may access the defect's project with at least
+// the required per-project role and returns the project ID. Anything above the
+// observer role means a write, which archived projects do not accept.
+func (s *Service) authorize(ctx context.Context, defectID string, actor domain.User, required string) (string, error) {
+	if !isUUID(defectID) {
+		return "", domain.ErrDefectNotFound
+	}
+	projectID, err := s.repo.GetProjectID(ctx, defectID)
+	if err != nil {
+		return "", err
 	}
+	return s.authorizeProject(ctx, projectID, actor, required)
+}
 
-	if !canTransition(defect.Status, nextStatus) {
-		return domain.Defect{}, fmt.Errorf("переход %s -> %s запрещён", defect.Status, nextStatus)
+func (s *Service) authorizeProject(ctx context.Context, projectID string, actor domain.User, required string) (string, error) {
+	role, err := s.access.MemberRole(ctx, projectID, actor.ID)
+	if err != nil {
+		return "", err
 	}
+	if !domain.ProjectRoleAtLeast(role, required) {
+		return "", domain.ErrNoProjectAccess
+	}
+	if required != domain.ProjectRoleObserver {
+		archived, err := s.access.IsArchived(ctx, projectID)
+		if err != nil {
+			return "", err
+		}
+		if archived {
+			return "", domain.ErrProjectArchived
+		}
+	}
+	return projectID, nil
+}
 
-	if requiresManager(nextStatus) && actor.Role != "manager" {
-		return domain.Defect{}, fmt.Errorf("статус %s доступен только менеджеру", nextStatus)
+// checkAssignee makes sure defects are only assigned to members of the project.
+func (s *Service) checkAssignee(ctx context.Context, projectID, assigneeID string) error {
+	assigneeID = strings.TrimSpace(assigneeID)
+	if assigneeID == "" {
+		return nil
 	}
+	if _, err := s.access.MemberRole(ctx, projectID, assigneeID); err != nil {
+		if errors.Is(err, domain.ErrNoProjectAccess) {
+			return ErrAssigneeNotMember
+		}
+		return err
+	}
+	return nil
+}
 
-	if err := s.repo.UpdateStatus(ctx, defectID, nextStatus, actor.ID); err != nil {
-		return domain.Defect{}, err
+func buildTimeline(defect domain.Defect) []domain.TimelineEvent {
+	events := make([]domain.TimelineEvent, 0, len(defect.History)+len(defect.Comments)+len(defect.Attachments))
+	for i := range defect.History {
+		events = append(events, domain.TimelineEvent{
+			Type:      domain.TimelineHistory,
+			CreatedAt: defect.History[i].CreatedAt,
+			History:   &defect.History[i],
+		})
+	}
+	for i := range defect.Comments {
+		events = append(events, domain.TimelineEvent{
+			Type:      domain.TimelineComment,
+			CreatedAt: defect.Comments[i].CreatedAt,
+			Comment:   &defect.Comments[i],
+		})
 	}
+	for i := range defect.Attachments {
+		events = append(events, domain.TimelineEvent{
+			Type:       domain.TimelineAttachment,
+			CreatedAt:  defect.Attachments[i].UploadedAt,
+			Attachment: &defect.Attachments[i],
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+	return events
+}
 
-	_ = s.repo.AddHistory(ctx, defectID, actor.ID, "status", defect.Status, nextStatus)
+type fieldChange struct {
+	field    string
+	oldValue string
+	newValue string
+}
 
-	return s.repo.GetByID(ctx, defectID)
+// collectChanges validates the payload against the current defect state and
+// drops fields that would not change anything, so only real edits are persisted.
+func collectChanges(current domain.Defect, payload *domain.DefectUpdate) ([]fieldChange, error) {
+	if payload.Title != nil {
+		title := strings.TrimSpace(*payload.Title)
+		if title == "" {
+			return nil, ErrTitleRequired
+		}
+		payload.Title = &title
+	}
+	if payload.Priority != nil {
+		priority := normalizeEnum(*payload.Priority, allowedPriorities)
+		if priority == "" {
+			return nil, ErrInvalidPriority
+		}
+		payload.Priority = &priority
+	}
+	if payload.Severity != nil {
+		severity := normalizeEnum(*payload.Severity, allowedSeverities)
+		if severity == "" {
+			return nil, ErrInvalidSeverity
+		}
+		payload.Severity = &severity
+	}
+	if payload.AssigneeID != nil {
+		assignee := strings.TrimSpace(*payload.AssigneeID)
+		payload.AssigneeID = &assignee
+	}
+
+	var changes []fieldChange
+	track := func(field string, value **string, oldValue string) {
+		if *value == nil {
+			return
+		}
+		if **value == oldValue {
+			*value = nil
+			return
+		}
+		changes = append(changes, fieldChange{field: field, oldValue: oldValue, newValue: **value})
+	}
+
+	track("title", &payload.Title, current.Title)
+	track("description", &payload.Description, current.Description)
+	track("priority", &payload.Priority, current.Priority)
+	track("severity", &payload.Severity, current.Severity)
+	track("assignee_id", &payload.AssigneeID, current.AssigneeID)
+
+	oldDue := formatDate(current.DueDate)
+	switch {
+	case payload.ClearDueDate:
+		payload.DueDate = nil
+		if oldDue == "" {
+			payload.ClearDueDate = false
+		} else {
+			changes = append(changes, fieldChange{field: "due_date", oldValue: oldDue})
+		}
+	case payload.DueDate != nil:
+		if newDue := formatDate(payload.DueDate); newDue == oldDue {
+			payload.DueDate = nil
+		} else {
+			changes = append(changes, fieldChange{field: "due_date", oldValue: oldDue, newValue: newDue})
+		}
+	}
+
+	return changes, nil
 }
 
-func canTransition(current, next string) bool {
-	transitions := map[string][]string{
-		"NEW":         {"IN_PROGRESS", "CANCELED"},
-		"IN_PROGRESS": {"IN_REVIEW", "CANCELED"},
-		"IN_REVIEW":   {"CLOSED", "CANCELED"},
-		"CLOSED":      {},
-		"CANCELED":    {},
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
+
+func checkRequiredFields(transition domain.WorkflowTransition, defect domain.Defect, comment, reason string) error {
+	if transition.Requires(domain.RequiredReason) && reason == "" {
+		return fmt.Errorf("для перехода %s -> %s нужно указать причину", transition.From, transition.To)
+	}
+	if transition.Requires(domain.RequiredComment) && comment == "" {
+		return fmt.Errorf("для перехода %s -> %s нужен комментарий", transition.From, transition.To)
+	}
+	if transition.Requires(domain.RequiredAssignee) && defect.AssigneeID == "" {
+		return fmt.Errorf("для перехода %s -> %s нужно назначить исполнителя", transition.From, transition.To)
 	}
-	for _, allowed := range transitions[current] {
-		if allowed == next {
-			return true
+	if transition.Requires(domain.RequiredDueDate) && defect.DueDate == nil {
+		return fmt.Errorf("для перехода %s -> %s нужно указать срок", transition.From, transition.To)
+	}
+	return nil
+}
+
+// normalizeStatus only canonicalises the code: the set of valid statuses depends on the project workflow.
+func normalizeStatus(value string) string {
+	return strings.ToUpper(strings.TrimSpace(value))
+}
+
+// normalizeValues applies normalize to every value, dropping empty results and duplicates.
+func normalizeValues(values []string, normalize func(string) string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = normalize(value)
+		if value == "" {
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		result = append(result, value)
+	}
+	return result
+}
+
+// resolveUserRef turns "me" into the actor id. "unassigned" is kept only
+// where a missing user makes sense.
+func resolveUserRef(value string, actor domain.User, allowNone bool) string {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case domain.AssigneeMe:
+		return actor.ID
+	case domain.AssigneeNone:
+		if allowNone {
+			return domain.AssigneeNone
 		}
+		return ""
 	}
-	return false
+	return value
 }
 
-func requiresManager(status string) bool {
-	return status == "CLOSED" || status == "CANCELED"
+// isUUID reports whether value is a UUID in the canonical 8-4-4-4-12 form.
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i, r := range value {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 func normalizeEnum(value string, allowed map[string]struct{}) string {
@@ -8,8 +8,16 @@ import (
 )
 
 type Repository interface {
-	List(ctx context.Context) ([]domain.Project, error)
+	List(ctx context.Context, userID string, includeArchived bool) ([]domain.Project, error)
+	GetByID(ctx context.Context, id string) (domain.Project, error)
 	Create(ctx context.Context, payload domain.ProjectCreate) (domain.Project, error)
+	Update(ctx context.Context, id string, payload domain.ProjectUpdate) error
+	SetArchived(ctx context.Context, id string, archived bool) error
+	Delete(ctx context.Context, id string) error
+	ListMembers(ctx context.Context, projectID string) ([]domain.ProjectMember, error)
+	GetMemberRole(ctx context.Context, projectID, userID string) (string, error)
+	SaveMember(ctx context.Context, projectID, userID, role string) error
+	RemoveMember(ctx context.Context, projectID, userID string) error
 }
 
 type Service struct {
@@ -20,8 +28,17 @@ func NewService(repo Repository) *Service {
 	return &Service{repo: repo}
 }
 
-func (s *Service) List(ctx context.Context) ([]domain.Project, error) {
-	return s.repo.List(ctx)
+// List returns projects visible to the user, i.e. the ones they are a member of.
+// Archived projects are hidden unless includeArchived is set.
+func (s *Service) List(ctx context.Context, userID string, includeArchived bool) ([]domain.Project, error) {
+	return s.repo.List(ctx, userID, includeArchived)
+}
+
+func (s *Service) Get(ctx context.Context, id string, actor domain.User) (domain.Project, error) {
+	if err := s.requireRole(ctx, id, actor, domain.ProjectRoleObserver); err != nil {
+		return domain.Project{}, err
+	}
+	return s.repo.GetByID(ctx, id)
 }
 
 func (s *Service) Create(ctx context.Context, payload domain.ProjectCreate) (domain.Project, error) {
@@ -34,7 +51,173 @@ func (s *Service) Create(ctx context.Context, payload domain.ProjectCreate) (dom
 	return s.repo.Create(ctx, payload)
 }
 
-var ErrValidation = domainError("validation error")
+func (s *Service) Update(ctx context.Context, id string, actor domain.User, payload domain.ProjectUpdate) (domain.Project, error) {
+	if err := s.requireRole(ctx, id, actor, domain.ProjectRoleManager); err != nil {
+		return domain.Project{}, err
+	}
+
+	current, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return domain.Project{}, err
+	}
+
+	if payload.Name != nil {
+		name := strings.TrimSpace(*payload.Name)
+		if name == "" {
+			return domain.Project{}, ErrValidation
+		}
+		payload.Name = &name
+	}
+	if payload.Stage != nil {
+		stage := strings.TrimSpace(*payload.Stage)
+		if stage == "" {
+			stage = "Не указан"
+		}
+		payload.Stage = &stage
+	}
+
+	start, end := current.StartDate, current.EndDate
+	if payload.ClearStartDate {
+		start = nil
+	} else if payload.StartDate != nil {
+		start = payload.StartDate
+	}
+	if payload.ClearEndDate {
+		end = nil
+	} else if payload.EndDate != nil {
+		end = payload.EndDate
+	}
+	if start != nil && end != nil && end.Before(*start) {
+		return domain.Project{}, ErrInvalidDates
+	}
+
+	if err := s.repo.Update(ctx, id, payload); err != nil {
+		return domain.Project{}, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// SetArchived archives or restores the project. Defects of archived projects are read-only.
+func (s *Service) SetArchived(ctx context.Context, id string, actor domain.User, archived bool) (domain.Project, error) {
+	if err := s.requireRole(ctx, id, actor, domain.ProjectRoleManager); err != nil {
+		return domain.Project{}, err
+	}
+	if err := s.repo.SetArchived(ctx, id, archived); err != nil {
+		return domain.Project{}, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// Delete removes the project; refused with domain.ErrProjectHasOpenDefects while defects are open.
+func (s *Service) Delete(ctx context.Context, id string, actor domain.User) error {
+	if err := s.requireRole(ctx, id, actor, domain.ProjectRoleManager); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *Service) IsArchived(ctx context.Context, id string) (bool, error) {
+	project, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return project.ArchivedAt != nil, nil
+}
+
+// MemberRole returns the per-project role of the user or domain.ErrNoProjectAccess.
+func (s *Service) MemberRole(ctx context.Context, projectID, userID string) (string, error) {
+	return s.repo.GetMemberRole(ctx, projectID, userID)
+}
+
+func (s *Service) ListMembers(ctx context.Context, projectID string, actor domain.User) ([]domain.ProjectMember, error) {
+	if err := s.requireRole(ctx, projectID, actor, domain.ProjectRoleObserver); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, projectID)
+}
+
+// SaveMember adds a user to the project or changes their role. Only project managers may do it.
+func (s *Service) SaveMember(ctx context.Context, projectID string, actor domain.User, userID, role string) (domain.ProjectMember, error) {
+	if err := s.requireRole(ctx, projectID, actor, domain.ProjectRoleManager); err != nil {
+		return domain.ProjectMember{}, err
+	}
+
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !isMemberRole(role) || strings.TrimSpace(userID) == "" {
+		return domain.ProjectMember{}, ErrValidation
+	}
+
+	if role != domain.ProjectRoleManager {
+		if err := s.ensureOtherManager(ctx, projectID, userID); err != nil {
+			return domain.ProjectMember{}, err
+		}
+	}
+
+	if err := s.repo.SaveMember(ctx, projectID, userID, role); err != nil {
+		return domain.ProjectMember{}, err
+	}
+
+	members, err := s.repo.ListMembers(ctx, projectID)
+	if err != nil {
+		return domain.ProjectMember{}, err
+	}
+	for _, member := range members {
+		if member.UserID == userID {
+			return member, nil
+		}
+	}
+	return domain.ProjectMember{}, domain.ErrMemberNotFound
+}
+
+func (s *Service) RemoveMember(ctx context.Context, projectID string, actor domain.User, userID string) error {
+	if err := s.requireRole(ctx, projectID, actor, domain.ProjectRoleManager); err != nil {
+		return err
+	}
+	if err := s.ensureOtherManager(ctx, projectID, userID); err != nil {
+		return err
+	}
+	return s.repo.RemoveMember(ctx, projectID, userID)
+}
+
+func (s *Service) requireRole(ctx context.Context, projectID string, actor domain.User, required string) error {
+	role, err := s.repo.GetMemberRole(ctx, projectID, actor.ID)
+	if err != nil {
+		return err
+	}
+	if !domain.ProjectRoleAtLeast(role, required) {
+		return domain.ErrNoProjectAccess
+	}
+	return nil
+}
+
+// ensureOtherManager refuses to demote or remove the last manager of the project.
+func (s *Service) ensureOtherManager(ctx context.Context, projectID, userID string) error {
+	members, err := s.repo.ListMembers(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.Role == domain.ProjectRoleManager && member.UserID != userID {
+			return nil
+		}
+	}
+	return ErrLastManager
+}
+
+func isMemberRole(role string) bool {
+	switch role {
+	case domain.ProjectRoleManager, domain.ProjectRoleEngineer, domain.ProjectRoleObserver:
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	ErrValidation   = domainError("validation error")
+	ErrInvalidDates = domainError("end date is before start date")
+	ErrLastManager  = domainError("project must keep at least one manager")
+)
 
 type domainError string
 
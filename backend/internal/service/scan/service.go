@@ -0,0 +1,157 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/scanner"
+	"defect-tracker/internal/pkg/storage"
+)
+
+// Repository records scan verdicts and lists attachments still waiting for one.
+type Repository interface {
+	SetScanResult(ctx context.Context, storageKey, status, signature string) error
+	ListPendingScans(ctx context.Context, before time.Time, after *domain.Attachment, limit int) ([]domain.Attachment, error)
+}
+
+// Options tune the service.
+type Options struct {
+	// MaxFileSize marks larger files too_large without streaming them to the
+	// scanner; zero leaves the limit to the scanner itself.
+	MaxFileSize int64
+}
+
+// Service moves attachments out of the pending_scan state. Uploads are scanned
+// right away; the ones whose scan failed, e.g. because the daemon was down,
+// are retried in the background. Files the scanner cannot accept because of
+// their size end up too_large and are not retried.
+type Service struct {
+	repo    Repository
+	scanner scanner.Scanner
+	storage storage.Provider
+	opts    Options
+	log     *zap.Logger
+}
+
+func NewService(repo Repository, scanner scanner.Scanner, files storage.Provider, opts Options, log *zap.Logger) *Service {
+	return &Service{repo: repo, scanner: scanner, storage: files, opts: opts, log: log}
+}
+
+// Scan checks the attachment content read from r and returns the attachment
+// with its new state. On error the attachment stays pending.
+func (s *Service) Scan(ctx context.Context, att domain.Attachment, r io.Reader) (domain.Attachment, error) {
+	if s.opts.MaxFileSize > 0 && att.SizeBytes > s.opts.MaxFileSize {
+		s.log.Warn("attachment too large to scan", zap.String("attachmentId", att.ID), zap.Int64("size", att.SizeBytes))
+		return s.record(ctx, att, domain.ScanTooLarge, "")
+	}
+	verdict, err := s.scanner.Scan(ctx, r)
+	if errors.Is(err, scanner.ErrTooLarge) {
+		s.log.Warn("attachment rejected by scanner as too large", zap.String("attachmentId", att.ID), zap.Int64("size", att.SizeBytes))
+		return s.record(ctx, att, domain.ScanTooLarge, "")
+	}
+	if err != nil {
+		s.log.Warn("attachment scan failed, left pending", zap.String("attachmentId", att.ID), zap.Error(err))
+		return att, fmt.Errorf("scan %s: %w", att.StorageKey, err)
+	}
+
+	status := domain.ScanClean
+	if verdict.Infected {
+		status = domain.ScanQuarantined
+		s.log.Warn("malware found in attachment",
+			zap.String("attachmentId", att.ID),
+			zap.String("defectId", att.DefectID),
+			zap.String("uploadedBy", att.UploadedBy),
+			zap.String("signature", verdict.Signature),
+		)
+	}
+	return s.record(ctx, att, status, verdict.Signature)
+}
+
+// record stores the final scan state for every attachment sharing the blob.
+func (s *Service) record(ctx context.Context, att domain.Attachment, status, signature string) (domain.Attachment, error) {
+	if err := s.repo.SetScanResult(ctx, att.StorageKey, status, signature); err != nil {
+		return att, err
+	}
+	now := time.Now()
+	att.ScanStatus, att.ScanSignature, att.ScannedAt = status, signature, &now
+	return att, nil
+}
+
+// ScanPending scans attachments left pending for longer than delay, which
+// keeps it from racing with scans of uploads in progress. Files the scanner
+// fails on are logged and stay pending for the next run; attachments whose
+// blob is gone are marked missing. It goes through the whole queue unless the
+// scanner is unreachable and returns the number of files scanned.
+func (s *Service) ScanPending(ctx context.Context, delay time.Duration) (int, error) {
+	const batch = 100
+	before := time.Now().Add(-delay)
+
+	scanned := 0
+	seen := make(map[string]bool)
+	var after *domain.Attachment
+	for {
+		pending, err := s.repo.ListPendingScans(ctx, before, after, batch)
+		if err != nil {
+			return scanned, err
+		}
+		for _, att := range pending {
+			// The verdict is stored for all attachments sharing the blob.
+			if seen[att.StorageKey] {
+				continue
+			}
+			seen[att.StorageKey] = true
+
+			object, err := s.storage.Open(ctx, att.StorageKey)
+			if errors.Is(err, storage.ErrNotFound) {
+				s.log.Warn("pending attachment has no blob, marked missing", zap.String("attachmentId", att.ID), zap.String("key", att.StorageKey))
+				if err := s.repo.SetScanResult(ctx, att.StorageKey, domain.ScanMissing, ""); err != nil {
+					return scanned, err
+				}
+				continue
+			}
+			if err != nil {
+				return scanned, err
+			}
+			_, err = s.Scan(ctx, att, object)
+			object.Close()
+			if errors.Is(err, scanner.ErrUnavailable) || ctx.Err() != nil {
+				return scanned, err
+			}
+			if err != nil {
+				// Scan has logged it; the file is retried on the next run.
+				continue
+			}
+			scanned++
+		}
+		if len(pending) < batch {
+			return scanned, nil
+		}
+		after = &pending[len(pending)-1]
+	}
+}
+
+// ScanPendingEvery runs ScanPending on a timer until ctx is cancelled.
+func (s *Service) ScanPendingEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			scanned, err := s.ScanPending(ctx, interval)
+			if err != nil {
+				s.log.Error("failed to scan pending attachments", zap.Error(err))
+			}
+			if scanned > 0 {
+				s.log.Info("pending attachments scanned", zap.Int("count", scanned))
+			}
+		}
+	}
+}
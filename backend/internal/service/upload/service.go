@@ -0,0 +1,280 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/storage"
+)
+
+// Repository persists upload progress between chunks.
+type Repository interface {
+	Create(ctx context.Context, upload domain.Upload) (domain.Upload, error)
+	Get(ctx context.Context, id string) (domain.Upload, error)
+	Claim(ctx context.Context, id string, offset int64, until time.Time) error
+	Release(ctx context.Context, id string) error
+	Advance(ctx context.Context, id string, offset int64, part domain.UploadPart, hashState []byte) error
+	Delete(ctx context.Context, id string) error
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Upload, error)
+}
+
+// AttachmentGuard checks access and quotas of the defect the file is uploaded to.
+type AttachmentGuard interface {
+	AuthorizeUpload(ctx context.Context, defectID string, actor domain.User) error
+	CheckResumableUploadSize(ctx context.Context, defectID string, size int64) error
+	GetAttachment(ctx context.Context, defectID, attachmentID string, actor domain.User) (domain.Attachment, error)
+}
+
+type Options struct {
+	// ChunkSize is what clients must send per request; raised to storage.MinPartSize.
+	ChunkSize int64
+	// TTL is how long an unfinished upload is kept.
+	TTL time.Duration
+	// ChunkTimeout bounds writing a single chunk; a claim older than that is
+	// considered abandoned.
+	ChunkTimeout time.Duration
+}
+
+// ErrUploadNotFound is returned for unknown uploads and uploads of other users.
+var ErrUploadNotFound = errors.New("upload not found")
+
+// Service implements resumable uploads: chunks are stored as parts of a
+// storage multipart upload and the file is assembled on completion.
+type Service struct {
+	repo    Repository
+	guard   AttachmentGuard
+	storage storage.Provider
+	opts    Options
+	log     *zap.Logger
+}
+
+func NewService(repo Repository, guard AttachmentGuard, files storage.Provider, opts Options, log *zap.Logger) *Service {
+	if opts.ChunkSize < storage.MinPartSize {
+		opts.ChunkSize = storage.MinPartSize
+	}
+	return &Service{repo: repo, guard: guard, storage: files, opts: opts, log: log}
+}
+
+// ChunkTimeout returns how long the transport may wait for a chunk body.
+func (s *Service) ChunkTimeout() time.Duration {
+	return s.opts.ChunkTimeout
+}
+
+// Create starts an upload of a file of the given size. Access, the replaced
+// attachment and the quota are checked up front so clients do not send
+// gigabytes only to be rejected; they are checked again on completion.
+func (s *Service) Create(ctx context.Context, actor domain.User, defectID, filename string, size int64, replacesID string) (domain.Upload, error) {
+	if err := s.guard.AuthorizeUpload(ctx, defectID, actor); err != nil {
+		return domain.Upload{}, err
+	}
+	if replacesID != "" {
+		if _, err := s.guard.GetAttachment(ctx, defectID, replacesID, actor); err != nil {
+			return domain.Upload{}, fmt.Errorf("attachment to replace not found")
+		}
+	}
+	filename = strings.TrimSpace(filename)
+	if filename == "" || size <= 0 {
+		return domain.Upload{}, fmt.Errorf("filename and size are required")
+	}
+	if err := s.guard.CheckResumableUploadSize(ctx, defectID, size); err != nil {
+		return domain.Upload{}, err
+	}
+
+	key, storageUploadID, err := s.storage.CreateMultipart(ctx, filename)
+	if err != nil {
+		return domain.Upload{}, fmt.Errorf("start multipart upload: %w", err)
+	}
+	upload, err := s.repo.Create(ctx, domain.Upload{
+		DefectID:        defectID,
+		Filename:        filename,
+		SizeBytes:       size,
+		ChunkSize:       s.opts.ChunkSize,
+		ReplacesID:      replacesID,
+		StorageKey:      key,
+		StorageUploadID: storageUploadID,
+		CreatedBy:       actor.ID,
+		ExpiresAt:       time.Now().Add(s.opts.TTL),
+	})
+	if err != nil {
+		_ = s.storage.AbortMultipart(context.WithoutCancel(ctx), key, storageUploadID)
+		return domain.Upload{}, err
+	}
+	return upload, nil
+}
+
+// Get returns the upload so a client can resume from its offset. Uploads are
+// private to their author.
+func (s *Service) Get(ctx context.Context, actor domain.User, defectID, uploadID string) (domain.Upload, error) {
+	upload, err := s.repo.Get(ctx, uploadID)
+	if err != nil || upload.DefectID != defectID || upload.CreatedBy != actor.ID {
+		return domain.Upload{}, ErrUploadNotFound
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return domain.Upload{}, domain.ErrUploadExpired
+	}
+	return upload, nil
+}
+
+// WriteChunk stores length bytes of r at offset. Every chunk but the last must
+// be exactly ChunkSize long, which keeps part boundaries fixed across retries.
+func (s *Service) WriteChunk(ctx context.Context, actor domain.User, defectID, uploadID string, offset int64, r io.Reader, length int64) (domain.Upload, error) {
+	upload, err := s.Get(ctx, actor, defectID, uploadID)
+	if err != nil {
+		return domain.Upload{}, err
+	}
+	if offset != upload.Offset {
+		return upload, domain.ErrUploadOffsetMismatch
+	}
+	if length != min(upload.ChunkSize, upload.SizeBytes-upload.Offset) {
+		return upload, domain.ErrUploadChunkSize
+	}
+	digest, err := restoreHash(upload.HashState)
+	if err != nil {
+		return upload, err
+	}
+
+	if err := s.repo.Claim(ctx, upload.ID, offset, time.Now().Add(s.opts.ChunkTimeout)); err != nil {
+		return upload, err
+	}
+	part, err := s.storage.UploadPart(ctx, upload.StorageKey, upload.StorageUploadID, len(upload.Parts)+1,
+		io.TeeReader(io.LimitReader(r, length), digest), length)
+	if err == nil && part.Size != length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		// The part is simply written again by the retried request.
+		_ = s.repo.Release(context.WithoutCancel(ctx), upload.ID)
+		return upload, err
+	}
+
+	state, err := digest.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return upload, err
+	}
+	stored := domain.UploadPart{Number: part.Number, ETag: part.ETag, SizeBytes: part.Size}
+	if err := s.repo.Advance(ctx, upload.ID, offset, stored, state); err != nil {
+		return upload, err
+	}
+	upload.Offset += length
+	upload.Parts = append(upload.Parts, stored)
+	upload.HashState = state
+	return upload, nil
+}
+
+// Complete assembles the received parts into a blob and removes the upload.
+// Creating the attachment row for the returned blob is up to the caller, who
+// must purge the blob if that fails.
+func (s *Service) Complete(ctx context.Context, actor domain.User, defectID, uploadID string) (domain.Upload, storage.Saved, error) {
+	upload, err := s.Get(ctx, actor, defectID, uploadID)
+	if err != nil {
+		return domain.Upload{}, storage.Saved{}, err
+	}
+	if !upload.Complete() {
+		return upload, storage.Saved{}, domain.ErrUploadIncomplete
+	}
+	digest, err := restoreHash(upload.HashState)
+	if err != nil {
+		return upload, storage.Saved{}, err
+	}
+	if err := s.repo.Claim(ctx, upload.ID, upload.Offset, time.Now().Add(s.opts.ChunkTimeout)); err != nil {
+		return upload, storage.Saved{}, err
+	}
+
+	parts := make([]storage.Part, 0, len(upload.Parts))
+	for _, part := range upload.Parts {
+		parts = append(parts, storage.Part{Number: part.Number, ETag: part.ETag, Size: part.SizeBytes})
+	}
+	if err := s.storage.CompleteMultipart(ctx, upload.StorageKey, upload.StorageUploadID, parts); err != nil {
+		_ = s.repo.Release(context.WithoutCancel(ctx), upload.ID)
+		return upload, storage.Saved{}, fmt.Errorf("complete multipart upload: %w", err)
+	}
+	if err := s.repo.Delete(context.WithoutCancel(ctx), upload.ID); err != nil {
+		return upload, storage.Saved{}, err
+	}
+	return upload, storage.Saved{
+		Key:    upload.StorageKey,
+		Size:   upload.SizeBytes,
+		SHA256: hex.EncodeToString(digest.Sum(nil)),
+	}, nil
+}
+
+// Abort discards the upload and the chunks stored so far.
+func (s *Service) Abort(ctx context.Context, actor domain.User, defectID, uploadID string) error {
+	upload, err := s.repo.Get(ctx, uploadID)
+	if err != nil || upload.DefectID != defectID || upload.CreatedBy != actor.ID {
+		return ErrUploadNotFound
+	}
+	return s.discard(ctx, upload)
+}
+
+// PurgeExpired aborts uploads nobody finished within the TTL. Without it parts
+// would stay in the storage forever: they are invisible to listings.
+func (s *Service) PurgeExpired(ctx context.Context) (int, error) {
+	const batch = 100
+	purged := 0
+	for {
+		uploads, err := s.repo.ListExpired(ctx, time.Now(), batch)
+		if err != nil {
+			return purged, err
+		}
+		for _, upload := range uploads {
+			if err := s.discard(ctx, upload); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(uploads) < batch {
+			return purged, nil
+		}
+	}
+}
+
+// PurgeExpiredEvery runs PurgeExpired on a timer until ctx is cancelled.
+func (s *Service) PurgeExpiredEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeExpired(ctx)
+			if err != nil {
+				s.log.Error("failed to purge expired uploads", zap.Error(err))
+			}
+			if purged > 0 {
+				s.log.Info("expired uploads purged", zap.Int("count", purged))
+			}
+		}
+	}
+}
+
+func (s *Service) discard(ctx context.Context, upload domain.Upload) error {
+	if err := s.storage.AbortMultipart(ctx, upload.StorageKey, upload.StorageUploadID); err != nil {
+		return fmt.Errorf("abort multipart upload: %w", err)
+	}
+	return s.repo.Delete(ctx, upload.ID)
+}
+
+// restoreHash resumes the SHA-256 of the bytes received so far.
+func restoreHash(state []byte) (hash.Hash, error) {
+	digest := sha256.New()
+	if len(state) == 0 {
+		return digest, nil
+	}
+	if err := digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return nil, fmt.Errorf("restore upload checksum: %w", err)
+	}
+	return digest, nil
+}
@@ -0,0 +1,242 @@
+package user
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/mailer"
+)
+
+// Invitation validation errors carry messages for the client.
+var (
+	ErrInvalidEmail = errors.New("некорректный email")
+	ErrInvalidRole  = errors.New("роль должна быть manager, engineer или observer")
+)
+
+// InvitationRepository stores invitations and turns accepted ones into accounts.
+type InvitationRepository interface {
+	Create(ctx context.Context, payload domain.InvitationCreate) (domain.Invitation, error)
+	GetByID(ctx context.Context, id string) (domain.Invitation, error)
+	ListPending(ctx context.Context, projectID string) ([]domain.Invitation, error)
+	Revoke(ctx context.Context, projectID, id string) error
+	MarkAccepted(ctx context.Context, id string) (domain.Invitation, error)
+	CreateAccount(ctx context.Context, email, fullName, passwordHash string) (domain.User, error)
+	AddMember(ctx context.Context, projectID, userID, role string) error
+}
+
+// Transactor runs fn atomically; repository calls made with the ctx passed to fn share the transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// ProjectAccess tells the per-project role of a user, see project.Service.MemberRole.
+type ProjectAccess interface {
+	MemberRole(ctx context.Context, projectID, userID string) (string, error)
+}
+
+type InvitationOptions struct {
+	// Secret signs invitation tokens.
+	Secret string
+	// TTL is how long an invitation can be accepted.
+	TTL time.Duration
+	// URL is the web client page that reads the token from the inviteToken query parameter.
+	URL string
+}
+
+// InvitationService lets project managers and administrators bring people in:
+// an invitation is bound to an email, a project and a role, and the invitee
+// sets a password through a signed link from the email.
+type InvitationService struct {
+	repo     InvitationRepository
+	tx       Transactor
+	projects ProjectAccess
+	mailer   mailer.Mailer
+	opts     InvitationOptions
+	log      *zap.Logger
+}
+
+func NewInvitationService(repo InvitationRepository, tx Transactor, projects ProjectAccess, mailer mailer.Mailer, opts InvitationOptions, log *zap.Logger) *InvitationService {
+	return &InvitationService{repo: repo, tx: tx, projects: projects, mailer: mailer, opts: opts, log: log}
+}
+
+// Invite creates an invitation and emails the link. Only managers of the
+// project and administrators may invite. It returns
+// domain.ErrEmailAlreadyExists for people who already have an account; a
+// manager adds them through the project members instead.
+func (s *InvitationService) Invite(ctx context.Context, actor domain.User, projectID, email, role string) (domain.Invitation, error) {
+	if err := s.requireManager(ctx, actor, projectID); err != nil {
+		return domain.Invitation{}, err
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return domain.Invitation{}, ErrInvalidEmail
+	}
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !isRoleAllowed(role) {
+		return domain.Invitation{}, ErrInvalidRole
+	}
+
+	inv, err := s.repo.Create(ctx, domain.InvitationCreate{
+		Email:     email,
+		ProjectID: projectID,
+		Role:      role,
+		InvitedBy: actor.ID,
+		ExpiresAt: time.Now().Add(s.opts.TTL),
+	})
+	if err != nil {
+		return domain.Invitation{}, err
+	}
+
+	link, err := tokenLink(s.opts.URL, "inviteToken", s.sign(inv.ID))
+	if err != nil {
+		return domain.Invitation{}, err
+	}
+	msg := mailer.Message{
+		To:      inv.Email,
+		Subject: "Приглашение в проект «" + inv.ProjectName + "»",
+		Body: fmt.Sprintf("Здравствуйте!\n\n%s приглашает вас в проект «%s» системы контроля дефектов с ролью «%s». Чтобы создать учётную запись и задать пароль, откройте ссылку:\n\n%s\n\nСсылка действует до %s и может быть использована один раз.\n",
+			actor.FullName, inv.ProjectName, roleTitle(inv.Role), link, inv.ExpiresAt.Format("02.01.2006 15:04 MST")),
+	}
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if err := s.mailer.Send(sendCtx, msg); err != nil {
+			s.log.Error("failed to send invitation email", zap.String("invitationId", inv.ID), zap.Error(err))
+		}
+	}()
+	return inv, nil
+}
+
+func (s *InvitationService) ListPending(ctx context.Context, actor domain.User, projectID string) ([]domain.Invitation, error) {
+	if err := s.requireManager(ctx, actor, projectID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListPending(ctx, projectID)
+}
+
+func (s *InvitationService) Revoke(ctx context.Context, actor domain.User, projectID, id string) error {
+	if err := s.requireManager(ctx, actor, projectID); err != nil {
+		return err
+	}
+	return s.repo.Revoke(ctx, projectID, id)
+}
+
+// Lookup returns the pending invitation behind a token, so that the client
+// can show whom and where it invites before the password is set.
+func (s *InvitationService) Lookup(ctx context.Context, token string) (domain.Invitation, error) {
+	id, ok := s.verify(token)
+	if !ok {
+		return domain.Invitation{}, domain.ErrInvitationInvalid
+	}
+	inv, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrInvitationNotFound) {
+		return domain.Invitation{}, domain.ErrInvitationInvalid
+	}
+	if err != nil {
+		return domain.Invitation{}, err
+	}
+	if inv.AcceptedAt != nil || !inv.ExpiresAt.After(time.Now()) {
+		return domain.Invitation{}, domain.ErrInvitationInvalid
+	}
+	return inv, nil
+}
+
+// Accept creates an engineer account for the invitee and makes it a member of
+// the project with the invited role. The email comes from the invitation and
+// cannot be changed.
+func (s *InvitationService) Accept(ctx context.Context, token, fullName, password string) (domain.User, error) {
+	id, ok := s.verify(token)
+	if !ok {
+		return domain.User{}, domain.ErrInvitationInvalid
+	}
+	fullName = strings.TrimSpace(fullName)
+	password = strings.TrimSpace(password)
+	if fullName == "" || password == "" {
+		return domain.User{}, fmt.Errorf("ФИО и пароль обязательны")
+	}
+	if err := validatePassword(password); err != nil {
+		return domain.User{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	var account domain.User
+	err = s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		inv, err := s.repo.MarkAccepted(ctx, id)
+		if err != nil {
+			return err
+		}
+		account, err = s.repo.CreateAccount(ctx, inv.Email, fullName, string(hash))
+		if err != nil {
+			return err
+		}
+		return s.repo.AddMember(ctx, inv.ProjectID, account.ID, inv.Role)
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	return account, nil
+}
+
+func (s *InvitationService) requireManager(ctx context.Context, actor domain.User, projectID string) error {
+	if actor.Role == domain.RoleAdmin {
+		return nil
+	}
+	role, err := s.projects.MemberRole(ctx, projectID, actor.ID)
+	if err != nil {
+		return err
+	}
+	if !domain.ProjectRoleAtLeast(role, domain.ProjectRoleManager) {
+		return domain.ErrNoProjectAccess
+	}
+	return nil
+}
+
+// Tokens are the invitation id with an HMAC of it. Nothing secret is stored,
+// and revoking or accepting the invitation makes the token useless.
+func (s *InvitationService) sign(id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(s.mac(id))
+}
+
+func (s *InvitationService) verify(token string) (string, bool) {
+	id, signature, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, s.mac(id)) {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *InvitationService) mac(id string) []byte {
+	h := hmac.New(sha256.New, []byte(s.opts.Secret))
+	h.Write([]byte("invitation:" + id))
+	return h.Sum(nil)
+}
+
+func roleTitle(role string) string {
+	switch role {
+	case domain.RoleManager:
+		return "менеджер"
+	case domain.RoleObserver:
+		return "наблюдатель"
+	default:
+		return "инженер"
+	}
+}
@@ -0,0 +1,166 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/mailer"
+)
+
+// resetThrottle limits how often one user can be sent a reset email.
+const resetThrottle = time.Minute
+
+// ResetRepository stores password reset tokens; only their hashes are kept.
+type ResetRepository interface {
+	GetByEmail(ctx context.Context, email string) (domain.User, error)
+	GetByID(ctx context.Context, id string) (domain.User, error)
+	UpdatePassword(ctx context.Context, id, passwordHash string) error
+	CreatePasswordReset(ctx context.Context, userID, tokenHash string, expiresAt time.Time, throttle time.Duration) (bool, error)
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (string, error)
+}
+
+type ResetOptions struct {
+	// TTL is how long an emailed link stays valid.
+	TTL time.Duration
+	// URL is the web client page that reads the token from the resetToken query parameter.
+	URL string
+}
+
+// ResetService lets users who forgot their password set a new one through a
+// single-use link sent by email.
+type ResetService struct {
+	repo   ResetRepository
+	mailer mailer.Mailer
+	opts   ResetOptions
+	log    *zap.Logger
+}
+
+func NewResetService(repo ResetRepository, mailer mailer.Mailer, opts ResetOptions, log *zap.Logger) *ResetService {
+	return &ResetService{repo: repo, mailer: mailer, opts: opts, log: log}
+}
+
+// RequestReset emails a reset link if the address belongs to a user. The
+// result does not tell whether it does, and the email is sent in the
+// background so that response times do not tell either.
+func (s *ResetService) RequestReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil || !user.IsActive {
+		return nil
+	}
+	return s.sendLink(ctx, user, resetThrottle,
+		"Для вашей учётной записи запрошено восстановление пароля. Чтобы задать новый пароль, откройте ссылку:",
+		"Если вы не запрашивали восстановление, просто проигнорируйте это письмо.")
+}
+
+// ForceReset is used by administrators: the current password stops working
+// and the user is emailed a link to set a new one. Sessions are revoked by
+// the caller.
+func (s *ResetService) ForceReset(ctx context.Context, userID string) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// Nobody knows this password, so only the emailed link lets the user in.
+	unusable, err := newResetToken()
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(unusable), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, user.ID, string(hash)); err != nil {
+		return err
+	}
+	return s.sendLink(ctx, user, 0,
+		"Администратор сбросил пароль вашей учётной записи. Чтобы задать новый пароль, откройте ссылку:",
+		"Прежний пароль больше не действует.")
+}
+
+// sendLink stores a new token, unless one was issued less than throttle ago,
+// and emails the link with the given text around it.
+func (s *ResetService) sendLink(ctx context.Context, user domain.User, throttle time.Duration, intro, outro string) error {
+	token, err := newResetToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.opts.TTL)
+	created, err := s.repo.CreatePasswordReset(ctx, user.ID, hashResetToken(token), expiresAt, throttle)
+	if err != nil || !created {
+		return err
+	}
+
+	link, err := tokenLink(s.opts.URL, "resetToken", token)
+	if err != nil {
+		return err
+	}
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Восстановление пароля",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n%s\n\n%s\n\nСсылка действует до %s и может быть использована один раз. %s\n",
+			user.FullName, intro, link, expiresAt.Format("02.01.2006 15:04 MST"), outro),
+	}
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if err := s.mailer.Send(sendCtx, msg); err != nil {
+			s.log.Error("failed to send password reset email", zap.String("userId", user.ID), zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Reset sets a new password by a token from the email and returns the user id
+// so that the caller can revoke existing sessions.
+func (s *ResetService) Reset(ctx context.Context, token, newPassword string) (string, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", domain.ErrResetTokenInvalid
+	}
+	if err := validatePassword(strings.TrimSpace(newPassword)); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(strings.TrimSpace(newPassword)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return s.repo.ResetPassword(ctx, hashResetToken(token), string(hash))
+}
+
+func newResetToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashResetToken is what the database keeps: a leaked table does not give
+// working links. The token is random, so an unsalted hash is enough.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenLink adds the token to the web client page under the given query parameter.
+func tokenLink(base, param, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid link url %q: %w", base, err)
+	}
+	query := link.Query()
+	query.Set(param, token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
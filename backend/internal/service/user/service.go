@@ -20,14 +20,23 @@ type Repository interface {
 	GetByID(ctx context.Context, id string) (domain.User, error)
 	UpdatePassword(ctx context.Context, id, passwordHash string) error
 	Create(ctx context.Context, email, fullName, role, passwordHash string) (domain.User, error)
+	List(ctx context.Context, filter domain.UserFilter) ([]domain.User, int, error)
+	UpdateAccount(ctx context.Context, id string, update domain.UserAccountUpdate) (domain.User, error)
+}
+
+type Options struct {
+	// OpenRegistration lets anyone sign up as an engineer, e.g. on demo
+	// instances. Otherwise accounts are created by invitation only.
+	OpenRegistration bool
 }
 
 type Service struct {
 	repo Repository
+	opts Options
 }
 
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo Repository, opts Options) *Service {
+	return &Service{repo: repo, opts: opts}
 }
 
 func (s *Service) Authenticate(ctx context.Context, email, password string) (domain.User, error) {
@@ -43,6 +52,9 @@ func (s *Service) Authenticate(ctx context.Context, email, password string) (dom
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		return domain.User{}, ErrInvalidCredentials
 	}
+	if !user.IsActive {
+		return domain.User{}, domain.ErrUserInactive
+	}
 	return user, nil
 }
 
@@ -50,25 +62,23 @@ func (s *Service) GetByID(ctx context.Context, id string) (domain.User, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
+// Register creates an engineer account when open registration is enabled and
+// returns domain.ErrRegistrationClosed otherwise.
 func (s *Service) Register(ctx context.Context, payload domain.UserRegister) (domain.User, error) {
+	if !s.opts.OpenRegistration {
+		return domain.User{}, domain.ErrRegistrationClosed
+	}
+
 	email := strings.ToLower(strings.TrimSpace(payload.Email))
 	fullName := strings.TrimSpace(payload.FullName)
 	password := strings.TrimSpace(payload.Password)
-	role := strings.TrimSpace(strings.ToLower(payload.Role))
 
 	if email == "" || fullName == "" || password == "" {
 		return domain.User{}, fmt.Errorf("email, ФИО и пароль обязательны")
 	}
 
-	if len(password) < 6 {
-		return domain.User{}, fmt.Errorf("пароль должен быть не короче 6 символов")
-	}
-
-	if role == "" {
-		role = "engineer"
-	}
-	if !isRoleAllowed(role) {
-		return domain.User{}, fmt.Errorf("недопустимая роль")
+	if err := validatePassword(password); err != nil {
+		return domain.User{}, err
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -76,7 +86,7 @@ func (s *Service) Register(ctx context.Context, payload domain.UserRegister) (do
 		return domain.User{}, err
 	}
 
-	user, err := s.repo.Create(ctx, email, fullName, role, string(hash))
+	user, err := s.repo.Create(ctx, email, fullName, domain.RoleEngineer, string(hash))
 	if err != nil {
 		if errors.Is(err, domain.ErrEmailAlreadyExists) {
 			return domain.User{}, fmt.Errorf("пользователь с таким email уже зарегистрирован")
@@ -102,9 +112,59 @@ func (s *Service) ChangePassword(ctx context.Context, id, currentPassword, newPa
 	return s.repo.UpdatePassword(ctx, id, string(newHash))
 }
 
+// List returns users matching the filter and their total count. Only
+// administrators reach it; the route is guarded by role.
+func (s *Service) List(ctx context.Context, filter domain.UserFilter) ([]domain.User, int, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.Role = strings.TrimSpace(strings.ToLower(filter.Role))
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return s.repo.List(ctx, filter)
+}
+
+// UpdateAccount changes the role or activity of a user on behalf of an
+// administrator. Administrators cannot demote or deactivate themselves, so
+// the system is never left without one by accident.
+func (s *Service) UpdateAccount(ctx context.Context, actor domain.User, id string, update domain.UserAccountUpdate) (domain.User, error) {
+	if update.Role != nil {
+		role := strings.TrimSpace(strings.ToLower(*update.Role))
+		if role != domain.RoleAdmin && !isRoleAllowed(role) {
+			return domain.User{}, fmt.Errorf("недопустимая роль")
+		}
+		update.Role = &role
+	}
+	if actor.ID == id {
+		if (update.Role != nil && *update.Role != domain.RoleAdmin) || (update.IsActive != nil && !*update.IsActive) {
+			return domain.User{}, domain.ErrSelfLockout
+		}
+	}
+	return s.repo.UpdateAccount(ctx, id, update)
+}
+
+// SetRole assigns a role by email outside of a request. It is used by the
+// set-role command to appoint the first administrator.
+func (s *Service) SetRole(ctx context.Context, email, role string) (domain.User, error) {
+	account, err := s.repo.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return domain.User{}, err
+	}
+	return s.UpdateAccount(ctx, domain.User{}, account.ID, domain.UserAccountUpdate{Role: &role})
+}
+
+func validatePassword(password string) error {
+	if len(password) < 6 {
+		return fmt.Errorf("пароль должен быть не короче 6 символов")
+	}
+	return nil
+}
+
 func isRoleAllowed(role string) bool {
 	switch role {
-	case "manager", "engineer", "observer":
+	case domain.RoleManager, domain.RoleEngineer, domain.RoleObserver:
 		return true
 	default:
 		return false
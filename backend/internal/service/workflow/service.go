@@ -0,0 +1,214 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"defect-tracker/internal/domain"
+)
+
+type Repository interface {
+	List(ctx context.Context) ([]domain.Workflow, error)
+	GetByID(ctx context.Context, id string) (domain.Workflow, error)
+	GetByProject(ctx context.Context, projectID string) (domain.Workflow, error)
+	Create(ctx context.Context, payload domain.WorkflowCreate) (domain.Workflow, error)
+	AssignToProject(ctx context.Context, projectID, workflowID string) error
+}
+
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// AccessChecker resolves per-project roles of users and project state.
+type AccessChecker interface {
+	MemberRole(ctx context.Context, projectID, userID string) (string, error)
+	IsArchived(ctx context.Context, projectID string) (bool, error)
+}
+
+// Service manages configurable defect workflows.
+type Service struct {
+	repo   Repository
+	tx     Transactor
+	access AccessChecker
+}
+
+var (
+	allowedRoles = map[string]struct{}{
+		domain.ProjectRoleManager:  {},
+		domain.ProjectRoleEngineer: {},
+		domain.ProjectRoleObserver: {},
+	}
+	allowedRequiredFields = map[string]struct{}{
+		domain.RequiredComment:  {},
+		domain.RequiredAssignee: {},
+		domain.RequiredDueDate:  {},
+		domain.RequiredReason:   {},
+	}
+)
+
+func NewService(repo Repository, tx Transactor, access AccessChecker) *Service {
+	return &Service{repo: repo, tx: tx, access: access}
+}
+
+func (s *Service) List(ctx context.Context) ([]domain.Workflow, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *Service) Get(ctx context.Context, id string) (domain.Workflow, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ForProject returns the workflow used by defects of the project.
+func (s *Service) ForProject(ctx context.Context, projectID string) (domain.Workflow, error) {
+	return s.repo.GetByProject(ctx, projectID)
+}
+
+func (s *Service) Create(ctx context.Context, payload domain.WorkflowCreate) (domain.Workflow, error) {
+	if err := normalizeWorkflow(&payload); err != nil {
+		return domain.Workflow{}, err
+	}
+
+	var created domain.Workflow
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.repo.Create(ctx, payload)
+		return err
+	})
+	return created, err
+}
+
+// ProjectWorkflow returns the workflow of the project to its members.
+func (s *Service) ProjectWorkflow(ctx context.Context, projectID string, actor domain.User) (domain.Workflow, error) {
+	if err := s.authorizeProject(ctx, projectID, actor, domain.ProjectRoleObserver); err != nil {
+		return domain.Workflow{}, err
+	}
+	return s.repo.GetByProject(ctx, projectID)
+}
+
+// AssignToProject switches the project to another workflow. Only managers of
+// the project may do it, and not while the project is archived.
+func (s *Service) AssignToProject(ctx context.Context, projectID string, actor domain.User, workflowID string) (domain.Workflow, error) {
+	if err := s.authorizeProject(ctx, projectID, actor, domain.ProjectRoleManager); err != nil {
+		return domain.Workflow{}, err
+	}
+
+	err := s.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.repo.GetByID(ctx, workflowID); err != nil {
+			return err
+		}
+		return s.repo.AssignToProject(ctx, projectID, workflowID)
+	})
+	if err != nil {
+		return domain.Workflow{}, err
+	}
+	return s.repo.GetByProject(ctx, projectID)
+}
+
+// authorizeProject checks the per-project role of the actor; anything above
+// observer also requires the project not to be archived.
+func (s *Service) authorizeProject(ctx context.Context, projectID string, actor domain.User, required string) error {
+	role, err := s.access.MemberRole(ctx, projectID, actor.ID)
+	if err != nil {
+		return err
+	}
+	if !domain.ProjectRoleAtLeast(role, required) {
+		return domain.ErrNoProjectAccess
+	}
+	if required != domain.ProjectRoleObserver {
+		archived, err := s.access.IsArchived(ctx, projectID)
+		if err != nil {
+			return err
+		}
+		if archived {
+			return domain.ErrProjectArchived
+		}
+	}
+	return nil
+}
+
+func normalizeWorkflow(payload *domain.WorkflowCreate) error {
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		return fmt.Errorf("название workflow обязательно")
+	}
+	if len(payload.Statuses) == 0 {
+		return fmt.Errorf("workflow должен содержать хотя бы один статус")
+	}
+
+	codes := make(map[string]struct{}, len(payload.Statuses))
+	initial := 0
+	for i := range payload.Statuses {
+		status := &payload.Statuses[i]
+		status.Code = strings.ToUpper(strings.TrimSpace(status.Code))
+		status.Name = strings.TrimSpace(status.Name)
+		if status.Code == "" {
+			return fmt.Errorf("код статуса обязателен")
+		}
+		if _, exists := codes[status.Code]; exists {
+			return fmt.Errorf("статус %s указан дважды", status.Code)
+		}
+		codes[status.Code] = struct{}{}
+		if status.Name == "" {
+			status.Name = status.Code
+		}
+		if status.Position == 0 {
+			status.Position = i + 1
+		}
+		if status.IsInitial {
+			initial++
+		}
+	}
+	if initial != 1 {
+		return fmt.Errorf("в workflow должен быть ровно один начальный статус")
+	}
+
+	pairs := make(map[string]struct{}, len(payload.Transitions))
+	for i := range payload.Transitions {
+		transition := &payload.Transitions[i]
+		transition.From = strings.ToUpper(strings.TrimSpace(transition.From))
+		transition.To = strings.ToUpper(strings.TrimSpace(transition.To))
+		if _, ok := codes[transition.From]; !ok {
+			return fmt.Errorf("неизвестный статус %s в переходе", transition.From)
+		}
+		if _, ok := codes[transition.To]; !ok {
+			return fmt.Errorf("неизвестный статус %s в переходе", transition.To)
+		}
+		if transition.From == transition.To {
+			return fmt.Errorf("переход %s -> %s не имеет смысла", transition.From, transition.To)
+		}
+		key := transition.From + "->" + transition.To
+		if _, exists := pairs[key]; exists {
+			return fmt.Errorf("переход %s указан дважды", key)
+		}
+		pairs[key] = struct{}{}
+
+		roles, err := normalizeList(transition.AllowedRoles, allowedRoles, "недопустимая роль %s")
+		if err != nil {
+			return err
+		}
+		transition.AllowedRoles = roles
+
+		fields, err := normalizeList(transition.RequiredFields, allowedRequiredFields, "неизвестное обязательное поле %s")
+		if err != nil {
+			return err
+		}
+		transition.RequiredFields = fields
+	}
+	return nil
+}
+
+func normalizeList(values []string, allowed map[string]struct{}, errFormat string) ([]string, error) {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if _, ok := allowed[value]; !ok {
+			return nil, fmt.Errorf(errFormat, value)
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
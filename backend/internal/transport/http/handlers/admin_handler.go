@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/service/token"
+	"defect-tracker/internal/service/user"
+	"defect-tracker/internal/transport/http/middleware"
+)
+
+// AdminHandler serves account management for administrators.
+type AdminHandler struct {
+	users  *user.Service
+	resets *user.ResetService
+	tokens *token.Service
+}
+
+func NewAdminHandler(users *user.Service, resets *user.ResetService, tokens *token.Service) *AdminHandler {
+	return &AdminHandler{users: users, resets: resets, tokens: tokens}
+}
+
+// Register mounts the routes under /admin behind the admin role check.
+func (h *AdminHandler) Register(rg *gin.RouterGroup, authMW *middleware.AuthMiddleware) {
+	admin := rg.Group("/admin")
+	admin.Use(authMW.RequireRoles(domain.RoleAdmin))
+	admin.GET("/users", h.listUsers)
+	admin.GET("/users/:id", h.getUser)
+	admin.PATCH("/users/:id", h.updateUser)
+	admin.POST("/users/:id/password-reset", h.forcePasswordReset)
+	admin.POST("/users/:id/revoke-sessions", h.revokeSessions)
+}
+
+func (h *AdminHandler) listUsers(c *gin.Context) {
+	filter := domain.UserFilter{
+		Query:  c.Query("q"),
+		Role:   c.Query("role"),
+		Limit:  parseLimit(c.DefaultQuery("limit", "50")),
+		Offset: parseOffset(c.DefaultQuery("offset", "0")),
+	}
+	if value := c.Query("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Параметр active должен быть true или false"})
+			return
+		}
+		filter.Active = &active
+	}
+
+	users, total, err := h.users.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось получить пользователей"})
+		return
+	}
+	items := make([]gin.H, 0, len(users))
+	for _, u := range users {
+		items = append(items, mapAccount(u))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+func (h *AdminHandler) getUser(c *gin.Context) {
+	account, err := h.users.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Пользователь не найден"})
+		return
+	}
+	c.JSON(http.StatusOK, mapAccount(account))
+}
+
+// updateUser changes the role or locks the user out. Deactivation also ends
+// all sessions, so the user loses access at once.
+func (h *AdminHandler) updateUser(c *gin.Context) {
+	var payload struct {
+		Role     *string `json:"role"`
+		IsActive *bool   `json:"isActive"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	actor, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	if _, err := h.users.GetByID(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Пользователь не найден"})
+		return
+	}
+
+	account, err := h.users.UpdateAccount(c.Request.Context(), actor, c.Param("id"), domain.UserAccountUpdate{
+		Role:     payload.Role,
+		IsActive: payload.IsActive,
+	})
+	if errors.Is(err, domain.ErrSelfLockout) {
+		c.JSON(http.StatusConflict, gin.H{"message": "Нельзя снять с себя роль администратора или заблокировать свою учётную запись"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if !account.IsActive {
+		if err := h.tokens.RevokeUserTokens(c.Request.Context(), account.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Учётная запись заблокирована, но не удалось завершить сессии"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, mapAccount(account))
+}
+
+// forcePasswordReset invalidates the password, ends all sessions and emails
+// the user a link to set a new password.
+func (h *AdminHandler) forcePasswordReset(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := h.users.GetByID(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Пользователь не найден"})
+		return
+	}
+	if err := h.resets.ForceReset(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось сбросить пароль"})
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Пароль сброшен, но не удалось завершить сессии"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Пароль сброшен, пользователю отправлена ссылка для установки нового"})
+}
+
+func (h *AdminHandler) revokeSessions(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := h.users.GetByID(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Пользователь не найден"})
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось завершить сессии"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Все сессии пользователя завершены"})
+}
+
+func mapAccount(u domain.User) gin.H {
+	return gin.H{
+		"id":        u.ID,
+		"email":     u.Email,
+		"fullName":  u.FullName,
+		"role":      u.Role,
+		"isActive":  u.IsActive,
+		"createdAt": u.CreatedAt,
+		"updatedAt": u.UpdatedAt,
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -14,19 +15,25 @@ import (
 )
 
 type AuthHandler struct {
-	users   *user.Service
-	tokens  *token.Service
-	manager *auth.Manager
+	users       *user.Service
+	resets      *user.ResetService
+	invitations *user.InvitationService
+	tokens      *token.Service
+	manager     *auth.Manager
 }
 
-func NewAuthHandler(users *user.Service, tokens *token.Service, manager *auth.Manager) *AuthHandler {
-	return &AuthHandler{users: users, tokens: tokens, manager: manager}
+func NewAuthHandler(users *user.Service, resets *user.ResetService, invitations *user.InvitationService, tokens *token.Service, manager *auth.Manager) *AuthHandler {
+	return &AuthHandler{users: users, resets: resets, invitations: invitations, tokens: tokens, manager: manager}
 }
 
 func (h *AuthHandler) RegisterPublic(rg *gin.RouterGroup) {
 	rg.POST("/auth/login", h.login)
 	rg.POST("/auth/register", h.register)
 	rg.POST("/auth/refresh", h.refresh)
+	rg.POST("/auth/password/forgot", h.forgotPassword)
+	rg.POST("/auth/password/reset", h.resetPassword)
+	rg.POST("/auth/invitations/lookup", h.lookupInvitation)
+	rg.POST("/auth/invitations/accept", h.acceptInvitation)
 }
 
 func (h *AuthHandler) RegisterProtected(rg *gin.RouterGroup) {
@@ -46,6 +53,10 @@ func (h *AuthHandler) login(c *gin.Context) {
 	}
 
 	userEntity, err := h.users.Authenticate(c.Request.Context(), payload.Email, payload.Password)
+	if errors.Is(err, domain.ErrUserInactive) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Учётная запись заблокирована, обратитесь к администратору"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Неверный логин или пароль"})
 		return
@@ -65,7 +76,6 @@ func (h *AuthHandler) register(c *gin.Context) {
 		Email    string `json:"email"`
 		FullName string `json:"fullName"`
 		Password string `json:"password"`
-		Role     string `json:"role"`
 	}
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
@@ -76,8 +86,11 @@ func (h *AuthHandler) register(c *gin.Context) {
 		Email:    payload.Email,
 		FullName: payload.FullName,
 		Password: payload.Password,
-		Role:     payload.Role,
 	})
+	if errors.Is(err, domain.ErrRegistrationClosed) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Регистрация только по приглашению менеджера проекта"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
@@ -113,6 +126,11 @@ func (h *AuthHandler) refresh(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Пользователь не найден"})
 		return
 	}
+	if !userEntity.IsActive {
+		_ = h.tokens.RevokeUserTokens(c.Request.Context(), userEntity.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Учётная запись заблокирована"})
+		return
+	}
 
 	h.respondWithTokens(c, userEntity, newRefresh.Token, newRefresh.ExpiresAt)
 }
@@ -164,6 +182,113 @@ func (h *AuthHandler) changePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Пароль обновлён"})
 }
 
+// forgotPassword answers the same way whether or not the email is registered.
+func (h *AuthHandler) forgotPassword(c *gin.Context) {
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Email обязателен"})
+		return
+	}
+
+	if err := h.resets.RequestReset(c.Request.Context(), payload.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось запросить восстановление пароля"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Если адрес зарегистрирован, на него отправлено письмо со ссылкой для восстановления пароля"})
+}
+
+func (h *AuthHandler) resetPassword(c *gin.Context) {
+	var payload struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректные данные"})
+		return
+	}
+
+	userID, err := h.resets.Reset(c.Request.Context(), payload.Token, payload.NewPassword)
+	if errors.Is(err, domain.ErrResetTokenInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Ссылка для восстановления недействительна или устарела"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// Whoever knew the old password loses access: all sessions are closed.
+	if err := h.tokens.RevokeUserTokens(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Пароль обновлён, но не удалось завершить сессии"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль обновлён, войдите с новым паролем"})
+}
+
+// lookupInvitation shows the invitee what they are invited to before they set
+// a password. The token is sent in the body to keep it out of access logs.
+func (h *AuthHandler) lookupInvitation(c *gin.Context) {
+	var payload struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректные данные"})
+		return
+	}
+
+	inv, err := h.invitations.Lookup(c.Request.Context(), payload.Token)
+	if errors.Is(err, domain.ErrInvitationInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Приглашение недействительно или устарело"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось проверить приглашение"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"email":       inv.Email,
+		"projectName": inv.ProjectName,
+		"role":        inv.Role,
+		"expiresAt":   inv.ExpiresAt,
+	})
+}
+
+// acceptInvitation creates the account of the invitee and signs them in.
+func (h *AuthHandler) acceptInvitation(c *gin.Context) {
+	var payload struct {
+		Token    string `json:"token"`
+		FullName string `json:"fullName"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректные данные"})
+		return
+	}
+
+	userEntity, err := h.invitations.Accept(c.Request.Context(), payload.Token, payload.FullName, payload.Password)
+	switch {
+	case errors.Is(err, domain.ErrInvitationInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Приглашение недействительно или устарело"})
+		return
+	case errors.Is(err, domain.ErrEmailAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"message": "Пользователь с таким email уже зарегистрирован, войдите в систему"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	refresh, err := h.tokens.Issue(c.Request.Context(), userEntity.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось выпустить refresh token"})
+		return
+	}
+
+	h.respondWithTokens(c, userEntity, refresh.Token, refresh.ExpiresAt)
+}
+
 func (h *AuthHandler) respondWithTokens(c *gin.Context, user domain.User, refreshToken string, refreshExpires time.Time) {
 	accessToken, err := h.manager.Generate(user)
 	if err != nil {
@@ -1,7 +1,15 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,47 +18,123 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/photometa"
 	"defect-tracker/internal/pkg/storage"
+	"defect-tracker/internal/pkg/thumbnail"
 	"defect-tracker/internal/service/defect"
+	"defect-tracker/internal/service/scan"
+	"defect-tracker/internal/service/upload"
 	"defect-tracker/internal/transport/http/middleware"
 )
 
 type DefectHandler struct {
 	service *defect.Service
+	uploads *upload.Service
+	scans   *scan.Service
 	storage storage.Provider
 }
 
-func NewDefectHandler(service *defect.Service, storage storage.Provider) *DefectHandler {
-	return &DefectHandler{service: service, storage: storage}
+func NewDefectHandler(service *defect.Service, uploads *upload.Service, scans *scan.Service, storage storage.Provider) *DefectHandler {
+	return &DefectHandler{service: service, uploads: uploads, scans: scans, storage: storage}
 }
 
 func (h *DefectHandler) Register(rg *gin.RouterGroup) {
 	rg.GET("/defects", h.list)
 	rg.POST("/defects", h.create)
 	rg.GET("/defects/:id", h.get)
+	rg.PATCH("/defects/:id", h.update)
+	rg.GET("/defects/:id/history", h.listHistory)
 	rg.GET("/defects/:id/comments", h.listComments)
 	rg.POST("/defects/:id/comments", h.addComment)
 	rg.POST("/defects/:id/attachments", h.addAttachment)
 	rg.PATCH("/defects/:id/status", h.updateStatus)
 	rg.GET("/defects/:id/attachments/:attachmentId", h.downloadAttachment)
+	rg.GET("/defects/:id/attachments/:attachmentId/thumbnail", h.downloadThumbnail)
+	rg.DELETE("/defects/:id/attachments/:attachmentId", h.deleteAttachment)
+	rg.POST("/defects/:id/attachments/:attachmentId/versions", h.replaceAttachment)
+	rg.GET("/defects/:id/attachments/:attachmentId/versions", h.listAttachmentVersions)
+	rg.GET("/defects/:id/attachments.zip", h.downloadAttachmentsZIP)
+	rg.GET("/projects/:id/attachments.zip", h.downloadProjectAttachmentsZIP)
+	rg.POST("/defects/:id/uploads", h.createUpload)
+	rg.GET("/defects/:id/uploads/:uploadId", h.getUpload)
+	rg.PATCH("/defects/:id/uploads/:uploadId", h.writeUploadChunk)
+	rg.POST("/defects/:id/uploads/:uploadId/complete", h.completeUpload)
+	rg.DELETE("/defects/:id/uploads/:uploadId", h.abortUpload)
 }
 
 func (h *DefectHandler) list(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
 	limit := parseLimit(c.DefaultQuery("limit", "20"))
+	query := strings.TrimSpace(c.Query("q"))
+	sortParam := c.Query("sort")
+	if sortParam == "" && query != "" {
+		sortParam = "relevance"
+	}
+	sort, sortDesc := parseSort(sortParam, c.Query("order"))
 	filter := domain.DefectFilter{
-		Status:   c.Query("status"),
-		Priority: c.Query("priority"),
-		Project:  c.Query("projectId"),
-		Limit:    limit,
+		Statuses:          parseList(c, "status"),
+		Priorities:        parseList(c, "priority"),
+		Severities:        parseList(c, "severity"),
+		Projects:          parseList(c, "projectId"),
+		Assignees:         parseList(c, "assignee"),
+		Creators:          parseList(c, "creator"),
+		Overdue:           c.Query("overdue") == "true",
+		HasGeotaggedPhoto: c.Query("hasGeotaggedPhoto") == "true",
+		Query:             query,
+		Limit:             limit,
+		Sort:              sort,
+		SortDesc:          sortDesc,
+		Cursor:            c.Query("cursor"),
+	}
+
+	dateParams := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"createdFrom", &filter.CreatedFrom},
+		{"createdTo", &filter.CreatedTo},
+		{"updatedFrom", &filter.UpdatedFrom},
+		{"updatedTo", &filter.UpdatedTo},
+		{"dueFrom", &filter.DueFrom},
+		{"dueTo", &filter.DueTo},
+	}
+	for _, param := range dateParams {
+		value, err := parseDate(c.Query(param.name))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректная дата в параметре " + param.name})
+			return
+		}
+		*param.target = value
 	}
 
-	items, err := h.service.List(c.Request.Context(), filter)
+	page, err := h.service.List(c.Request.Context(), user, filter)
+	if errors.Is(err, domain.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный курсор пагинации"})
+		return
+	}
+	if errors.Is(err, domain.ErrInvalidFilterID) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный идентификатор проекта, исполнителя или автора в фильтре"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось загрузить дефекты"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"items": mapListResponse(items)})
+	var nextCursor *string
+	if page.NextCursor != "" {
+		nextCursor = &page.NextCursor
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":      mapListResponse(page.Items),
+		"total":      page.Total,
+		"nextCursor": nextCursor,
+	})
 }
 
 func (h *DefectHandler) create(c *gin.Context) {
@@ -75,18 +159,13 @@ func (h *DefectHandler) create(c *gin.Context) {
 		return
 	}
 
-	if user.Role != "manager" {
-		c.JSON(http.StatusForbidden, gin.H{"message": "Создавать дефекты может только менеджер"})
-		return
-	}
-
 	due, err := parseDate(payload.DueDate)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректная дата срока"})
 		return
 	}
 
-	defectEntity, err := h.service.Create(c.Request.Context(), domain.DefectCreate{
+	defectEntity, err := h.service.Create(c.Request.Context(), user, domain.DefectCreate{
 		ProjectID:   payload.ProjectID,
 		Title:       payload.Title,
 		Description: payload.Description,
@@ -97,6 +176,13 @@ func (h *DefectHandler) create(c *gin.Context) {
 		CreatedBy:   user.ID,
 	})
 	if err != nil {
+		if errors.Is(err, domain.ErrNoProjectAccess) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Создавать дефекты может только менеджер проекта"})
+			return
+		}
+		if denyIfForbidden(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
@@ -105,7 +191,31 @@ func (h *DefectHandler) create(c *gin.Context) {
 }
 
 func (h *DefectHandler) get(c *gin.Context) {
-	defectEntity, err := h.service.Get(c.Request.Context(), c.Param("id"))
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	if c.Query("include") == "timeline" {
+		defectEntity, timeline, err := h.service.GetWithTimeline(c.Request.Context(), c.Param("id"), user)
+		if denyIfForbidden(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Дефект не найден"})
+			return
+		}
+		response := h.mapDefect(c, defectEntity)
+		response["timeline"] = h.mapTimeline(c, defectEntity.ID, timeline)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	defectEntity, err := h.service.Get(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "Дефект не найден"})
 		return
@@ -113,8 +223,97 @@ func (h *DefectHandler) get(c *gin.Context) {
 	c.JSON(http.StatusOK, h.mapDefect(c, defectEntity))
 }
 
+func (h *DefectHandler) listHistory(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	limit := parseLimit(c.DefaultQuery("limit", "50"))
+	offset := parseOffset(c.DefaultQuery("offset", "0"))
+
+	entries, total, err := h.service.ListHistory(c.Request.Context(), c.Param("id"), user, limit, offset)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось получить историю изменений"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": mapHistory(entries), "total": total})
+}
+
+func (h *DefectHandler) update(c *gin.Context) {
+	var payload struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Priority    *string `json:"priority"`
+		Severity    *string `json:"severity"`
+		AssigneeID  *string `json:"assigneeId"`
+		DueDate     *string `json:"dueDate"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	update := domain.DefectUpdate{
+		Title:       payload.Title,
+		Description: payload.Description,
+		Priority:    payload.Priority,
+		Severity:    payload.Severity,
+		AssigneeID:  payload.AssigneeID,
+	}
+	if payload.DueDate != nil {
+		due, err := parseDate(*payload.DueDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректная дата срока"})
+			return
+		}
+		update.DueDate = due
+		update.ClearDueDate = due == nil
+	}
+
+	defectEntity, err := h.service.Update(c.Request.Context(), c.Param("id"), user, update)
+	if errors.Is(err, domain.ErrNoProjectAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Редактировать дефекты может только менеджер проекта"})
+		return
+	}
+	if denyIfForbidden(c, err) {
+		return
+	}
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, h.mapDefect(c, defectEntity))
+	case errors.Is(err, domain.ErrDefectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Дефект не найден"})
+	case errors.Is(err, defect.ErrTitleRequired), errors.Is(err, defect.ErrInvalidPriority),
+		errors.Is(err, defect.ErrInvalidSeverity), errors.Is(err, defect.ErrAssigneeNotMember):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось обновить дефект"})
+	}
+}
+
 func (h *DefectHandler) listComments(c *gin.Context) {
-	comments, err := h.service.ListComments(c.Request.Context(), c.Param("id"))
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	comments, err := h.service.ListComments(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось получить комментарии"})
 		return
@@ -136,11 +335,14 @@ func (h *DefectHandler) addComment(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
 		return
 	}
-	comment, err := h.service.AddComment(c.Request.Context(), domain.CommentCreate{
+	comment, err := h.service.AddComment(c.Request.Context(), user, domain.CommentCreate{
 		DefectID: c.Param("id"),
 		AuthorID: user.ID,
 		Body:     payload.Body,
 	})
+	if denyIfForbidden(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
@@ -149,11 +351,53 @@ func (h *DefectHandler) addComment(c *gin.Context) {
 }
 
 func (h *DefectHandler) addAttachment(c *gin.Context) {
+	h.uploadAttachment(c, "")
+}
+
+// replaceAttachment uploads a new version of the attachment; previous versions are kept.
+func (h *DefectHandler) replaceAttachment(c *gin.Context) {
+	h.uploadAttachment(c, c.Param("attachmentId"))
+}
+
+func (h *DefectHandler) uploadAttachment(c *gin.Context, replacesID string) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	err := h.service.AuthorizeUpload(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Дефект не найден"})
+		return
+	}
+	if replacesID != "" {
+		if _, err := h.service.GetAttachment(c.Request.Context(), c.Param("id"), replacesID, user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Вложение не найдено"})
+			return
+		}
+	}
+
+	if limit := h.service.MaxUploadSize(); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverhead)
+	}
 	formFile, err := c.FormFile("file")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondUploadError(c, domain.ErrFileTooLarge)
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Файл обязателен"})
 		return
 	}
+	if err := h.service.CheckUploadSize(c.Request.Context(), c.Param("id"), formFile.Size); err != nil {
+		respondUploadError(c, err)
+		return
+	}
 	file, err := formFile.Open()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Не удалось прочитать файл"})
@@ -161,30 +405,92 @@ func (h *DefectHandler) addAttachment(c *gin.Context) {
 	}
 	defer file.Close()
 
-	storageKey, size, err := h.storage.Save(c.Request.Context(), file, formFile.Filename, formFile.Size, formFile.Header.Get("Content-Type"))
+	head := make([]byte, defect.SniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Не удалось прочитать файл"})
+		return
+	}
+	contentType, err := defect.DetectContentType(head[:n])
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось прочитать файл"})
+		return
+	}
+
+	saved, err := h.storage.Save(c.Request.Context(), file, formFile.Filename, formFile.Size, contentType)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось сохранить файл"})
 		return
 	}
+	h.attachSaved(c, user, replacesID, formFile.Filename, contentType, saved, file)
+}
+
+// attachSaved finishes an upload whose content is already in the storage:
+// renders previews, reads EXIF, creates the attachment row and scans the file
+// for malware. content is the same file, used for reading; the blob is purged
+// when the row is not created.
+func (h *DefectHandler) attachSaved(c *gin.Context, user domain.User, replacesID, filename, contentType string, saved storage.Saved, content io.ReadSeeker) {
+	// Previews of a duplicate already exist next to the stored original.
+	_, findErr := h.service.FindBlob(c.Request.Context(), saved.SHA256)
+	duplicate := findErr == nil
+
+	hasThumbnails := false
+	if thumbnail.Supports(contentType) && !duplicate {
+		if _, err := content.Seek(0, io.SeekStart); err == nil {
+			hasThumbnails = h.storeThumbnails(c.Request.Context(), saved.Key, content)
+		}
+	}
 
-	attachment, err := h.service.AddAttachment(c.Request.Context(), domain.AttachmentCreate{
-		DefectID:    c.Param("id"),
-		Filename:    formFile.Filename,
-		ContentType: formFile.Header.Get("Content-Type"),
-		SizeBytes:   size,
-		StorageKey:  storageKey,
+	var photo domain.PhotoMetadata
+	if photometa.Supports(contentType) {
+		if _, err := content.Seek(0, io.SeekStart); err == nil {
+			photo = photometa.Extract(content)
+		}
+	}
+
+	attachment, err := h.service.AddAttachment(c.Request.Context(), user, domain.AttachmentCreate{
+		DefectID:      c.Param("id"),
+		Filename:      filename,
+		ContentType:   contentType,
+		SizeBytes:     saved.Size,
+		StorageKey:    saved.Key,
+		Checksum:      saved.SHA256,
+		HasThumbnails: hasThumbnails,
+		Photo:         photo,
+		ReplacesID:    replacesID,
 	})
+	if err != nil || attachment.StorageKey != saved.Key {
+		h.purgeBlob(c.Request.Context(), saved.Key, hasThumbnails)
+	}
+	if denyIfForbidden(c, err) || respondUploadError(c, err) || respondAttachmentError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
+	// The file stays pending_scan if the scanner fails; it is retried in the background.
+	// Files above the scanner size limit become too_large instead.
+	if _, err := content.Seek(0, io.SeekStart); err == nil {
+		if scanned, err := h.scans.Scan(c.Request.Context(), attachment, content); err == nil {
+			attachment = scanned
+		}
+	}
+
 	c.JSON(http.StatusCreated, h.mapSingleAttachment(c, c.Param("id"), attachment))
 }
 
 func (h *DefectHandler) updateStatus(c *gin.Context) {
 	var payload struct {
-		Status string `json:"status"`
+		Status         string `json:"status"`
+		ExpectedStatus string `json:"expectedStatus"`
+		Comment        string `json:"comment"`
+		Reason         string `json:"reason"`
 	}
 
 	if err := c.ShouldBindJSON(&payload); err != nil {
@@ -198,31 +504,232 @@ func (h *DefectHandler) updateStatus(c *gin.Context) {
 		return
 	}
 
-	defect, err := h.service.UpdateStatus(c.Request.Context(), c.Param("id"), user, payload.Status)
+	defect, err := h.service.UpdateStatus(c.Request.Context(), c.Param("id"), user, domain.StatusChange{
+		Status:         payload.Status,
+		ExpectedStatus: payload.ExpectedStatus,
+		Comment:        payload.Comment,
+		Reason:         payload.Reason,
+	})
 	if err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Статус дефекта уже изменён другим пользователем"})
+			return
+		}
+		if denyIfForbidden(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, h.mapDefect(c, defect))
 }
 
-func (h *DefectHandler) downloadAttachment(c *gin.Context) {
-	attachment, err := h.service.GetAttachment(c.Request.Context(), c.Param("id"), c.Param("attachmentId"))
+func (h *DefectHandler) deleteAttachment(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	released, err := h.service.DeleteAttachment(c.Request.Context(), c.Param("id"), c.Param("attachmentId"), user)
+	if denyIfForbidden(c, err) || respondAttachmentError(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "Вложение не найдено"})
 		return
 	}
 
-	local, ok := h.storage.(interface {
-		PathFor(string) string
-	})
+	// Rows stay soft-deleted for the audit trail, files no other attachment
+	// shares are purged.
+	for _, blob := range released {
+		h.purgeBlob(c.Request.Context(), blob.StorageKey, blob.HasThumbnails)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *DefectHandler) listAttachmentVersions(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	versions, err := h.service.ListAttachmentVersions(c.Request.Context(), c.Param("id"), c.Param("attachmentId"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Вложение не найдено"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(versions))
+	for _, att := range versions {
+		result = append(result, h.mapSingleAttachment(c, c.Param("id"), att))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": result})
+}
+
+// purgeBlob removes the file and its thumbnails. Failures are left to the
+// orphaned blob cleanup rather than failing the request.
+func (h *DefectHandler) purgeBlob(ctx context.Context, storageKey string, hasThumbnails bool) {
+	ctx = context.WithoutCancel(ctx)
+	_ = h.storage.Delete(ctx, storageKey)
+	if hasThumbnails {
+		for _, size := range thumbnail.Sizes {
+			_ = h.storage.Delete(ctx, thumbnail.Key(storageKey, size.Name))
+		}
+	}
+}
+
+// storeThumbnails renders previews of an uploaded image next to the original.
+// A photo that cannot be decoded is still accepted, just without previews.
+func (h *DefectHandler) storeThumbnails(ctx context.Context, storageKey string, r io.Reader) bool {
+	previews, err := thumbnail.Generate(r)
+	if err != nil {
+		return false
+	}
+	for name, data := range previews {
+		if _, err := h.storage.Put(ctx, thumbnail.Key(storageKey, name), bytes.NewReader(data), int64(len(data)), thumbnail.ContentType); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *DefectHandler) downloadThumbnail(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
 	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Загрузка через API недоступна для выбранного хранилища"})
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	size := c.DefaultQuery("size", thumbnail.DefaultSize)
+	if _, ok := thumbnail.FindSize(size); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Неизвестный размер превью"})
+		return
+	}
+
+	attachment, err := h.service.GetAttachment(c.Request.Context(), c.Param("id"), c.Param("attachmentId"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Вложение не найдено"})
+		return
+	}
+	if respondAttachmentError(c, attachment.CheckDownloadable()) {
+		return
+	}
+	if !attachment.HasThumbnails {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Для вложения нет превью"})
 		return
 	}
 
-	fullPath := local.PathFor(attachment.StorageKey)
-	c.FileAttachment(fullPath, attachment.Filename)
+	object, err := h.storage.Open(c.Request.Context(), thumbnail.Key(attachment.StorageKey, size))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Превью не найдено в хранилище"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось открыть превью"})
+		return
+	}
+	defer object.Close()
+
+	c.Header("Content-Type", thumbnail.ContentType)
+	c.Header("Cache-Control", "private, max-age=86400")
+	if object.ETag != "" {
+		c.Header("ETag", object.ETag)
+	}
+	extendDownloadDeadline(c, object.Size)
+	http.ServeContent(c.Writer, c.Request, "", object.ModTime, object)
+}
+
+func (h *DefectHandler) downloadAttachment(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	attachment, err := h.service.GetAttachment(c.Request.Context(), c.Param("id"), c.Param("attachmentId"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Вложение не найдено"})
+		return
+	}
+	if respondAttachmentError(c, attachment.CheckDownloadable()) {
+		return
+	}
+
+	object, err := h.storage.Open(c.Request.Context(), attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Файл вложения не найден в хранилище"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось открыть файл"})
+		return
+	}
+	defer object.Close()
+
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = object.ContentType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	if object.ETag != "" {
+		c.Header("ETag", object.ETag)
+	}
+	if digest := reprDigest(attachment.Checksum); digest != "" {
+		c.Header("Repr-Digest", digest)
+	}
+	extendDownloadDeadline(c, attachment.SizeBytes)
+	// ServeContent handles Range, If-Range and conditional requests using the ETag above.
+	http.ServeContent(c.Writer, c.Request, attachment.Filename, object.ModTime, object)
+}
+
+// downloadMinRate is the slowest transfer rate, in bytes per second, a download
+// is allowed to run at before the write deadline cuts it off.
+const downloadMinRate = 32 << 10
+
+// extendDownloadDeadline replaces SERVER_WRITE_TIMEOUT for a file of the given
+// size, which would otherwise cut large files off on slow site connections.
+func extendDownloadDeadline(c *gin.Context, size int64) {
+	timeout := exportFileTimeout + time.Duration(size/downloadMinRate)*time.Second
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout))
+}
+
+// reprDigest formats the checksum as an RFC 9530 Repr-Digest value so clients
+// can verify the whole downloaded file.
+func reprDigest(checksum string) string {
+	sum, err := hex.DecodeString(checksum)
+	if err != nil || len(sum) != sha256.Size {
+		return ""
+	}
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+// denyIfForbidden answers 403 when the service refused access to the project
+// or the project is archived and therefore read-only.
+func denyIfForbidden(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrNoProjectAccess):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Нет доступа к проекту"})
+		return true
+	case errors.Is(err, domain.ErrProjectArchived):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Проект в архиве, изменения недоступны"})
+		return true
+	}
+	return false
 }
 
 func parseLimit(value string) int {
@@ -233,6 +740,94 @@ func parseLimit(value string) int {
 	return limit
 }
 
+// multipartOverhead leaves room for multipart headers and other form fields
+// when the request body is capped by the file size limit.
+const multipartOverhead = 1 << 20
+
+// respondUploadError maps attachment limit errors to 413/415 and reports whether it responded.
+func respondUploadError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "Файл превышает допустимый размер"})
+	case errors.Is(err, domain.ErrAttachmentQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "Превышен допустимый объём вложений дефекта"})
+	case errors.Is(err, domain.ErrUnsupportedAttachmentType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"message": "Недопустимый тип файла: разрешены фотографии, видео, чертежи DWG, PDF и офисные документы"})
+	default:
+		return false
+	}
+	return true
+}
+
+// respondAttachmentError maps attachment ownership, versioning and malware scan
+// errors and reports whether it responded.
+func respondAttachmentError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrAttachmentForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Изменять вложение может только его автор или менеджер проекта"})
+	case errors.Is(err, domain.ErrAttachmentSuperseded):
+		c.JSON(http.StatusConflict, gin.H{"message": "Вложение уже заменено более новой версией"})
+	case errors.Is(err, domain.ErrAttachmentNotScanned):
+		c.Header("Retry-After", "60")
+		c.JSON(http.StatusConflict, gin.H{"message": "Файл ещё проверяется антивирусом, повторите попытку позже"})
+	case errors.Is(err, domain.ErrAttachmentQuarantined):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Файл заблокирован: антивирус обнаружил вредоносное содержимое"})
+	case errors.Is(err, domain.ErrAttachmentMissing):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Файл вложения не найден в хранилище"})
+	case errors.Is(err, domain.ErrAttachmentTooLargeToScan):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Файл слишком большой для антивирусной проверки, скачивание недоступно"})
+	default:
+		return false
+	}
+	return true
+}
+
+// parseSort maps API sort names to domain sort keys. Without an explicit order,
+// dates of creation/update and priority are sorted descending, the rest ascending.
+func parseSort(sort, order string) (string, bool) {
+	keys := map[string]string{
+		"createdAt": domain.SortCreatedAt,
+		"updatedAt": domain.SortUpdatedAt,
+		"dueDate":   domain.SortDueDate,
+		"priority":  domain.SortPriority,
+		"status":    domain.SortStatus,
+		"relevance": domain.SortRelevance,
+	}
+	key, ok := keys[sort]
+	if !ok {
+		return domain.SortCreatedAt, true
+	}
+	switch strings.ToLower(order) {
+	case "asc":
+		return key, false
+	case "desc":
+		return key, true
+	}
+	return key, key == domain.SortCreatedAt || key == domain.SortUpdatedAt || key == domain.SortPriority || key == domain.SortRelevance
+}
+
+func parseOffset(value string) int {
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+// parseList collects a multi-value query parameter given either repeated
+// (?status=NEW&status=IN_PROGRESS) or comma-separated (?status=NEW,IN_PROGRESS).
+func parseList(c *gin.Context, name string) []string {
+	var result []string
+	for _, raw := range c.QueryArray(name) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				result = append(result, value)
+			}
+		}
+	}
+	return result
+}
+
 func parseDate(value string) (*time.Time, error) {
 	if strings.TrimSpace(value) == "" {
 		return nil, nil
@@ -252,18 +847,27 @@ func mapListResponse(items []domain.DefectListItem) []gin.H {
 			formatted := item.DueDate.Format(time.DateOnly)
 			due = &formatted
 		}
-		result = append(result, gin.H{
-			"id":         item.ID,
-			"projectId":  item.ProjectID,
-			"project":    item.ProjectName,
-			"title":      item.Title,
-			"priority":   item.Priority,
-			"status":     item.Status,
-			"assigneeId": item.AssigneeID,
-			"assignee":   item.AssigneeName,
-			"dueDate":    due,
-			"updatedAt":  item.UpdatedAt,
-		})
+		entry := gin.H{
+			"id":          item.ID,
+			"projectId":   item.ProjectID,
+			"project":     item.ProjectName,
+			"title":       item.Title,
+			"priority":    item.Priority,
+			"status":      item.Status,
+			"assigneeId":  item.AssigneeID,
+			"assignee":    item.AssigneeName,
+			"dueDate":     due,
+			"reopenCount": item.ReopenCount,
+			"updatedAt":   item.UpdatedAt,
+		}
+		if item.Highlight != nil {
+			entry["highlight"] = gin.H{
+				"title":       item.Highlight.Title,
+				"description": item.Highlight.Description,
+				"comment":     item.Highlight.Comment,
+			}
+		}
+		result = append(result, entry)
 	}
 	return result
 }
@@ -289,6 +893,7 @@ func (h *DefectHandler) mapDefect(c *gin.Context, d domain.Defect) gin.H {
 		"assigneeId":  d.AssigneeID,
 		"assignee":    d.Assignee,
 		"dueDate":     due,
+		"reopenCount": d.ReopenCount,
 		"createdBy":   d.CreatedBy,
 		"createdAt":   d.CreatedAt,
 		"updatedAt":   d.UpdatedAt,
@@ -315,6 +920,47 @@ func mapComment(comment domain.Comment) gin.H {
 	}
 }
 
+func mapHistory(entries []domain.HistoryEntry) []gin.H {
+	result := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, mapHistoryEntry(entry))
+	}
+	return result
+}
+
+func mapHistoryEntry(entry domain.HistoryEntry) gin.H {
+	return gin.H{
+		"id":        entry.ID,
+		"actorId":   entry.ActorID,
+		"actor":     entry.ActorName,
+		"field":     entry.Field,
+		"oldValue":  entry.OldValue,
+		"newValue":  entry.NewValue,
+		"reason":    entry.Reason,
+		"createdAt": entry.CreatedAt,
+	}
+}
+
+func (h *DefectHandler) mapTimeline(c *gin.Context, defectID string, events []domain.TimelineEvent) []gin.H {
+	result := make([]gin.H, 0, len(events))
+	for _, event := range events {
+		item := gin.H{
+			"type":      event.Type,
+			"createdAt": event.CreatedAt,
+		}
+		switch {
+		case event.History != nil:
+			item["history"] = mapHistoryEntry(*event.History)
+		case event.Comment != nil:
+			item["comment"] = mapComment(*event.Comment)
+		case event.Attachment != nil:
+			item["attachment"] = h.mapSingleAttachment(c, defectID, *event.Attachment)
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
 func (h *DefectHandler) mapAttachments(c *gin.Context, defect domain.Defect) []gin.H {
 	result := make([]gin.H, 0, len(defect.Attachments))
 	for _, att := range defect.Attachments {
@@ -324,21 +970,43 @@ func (h *DefectHandler) mapAttachments(c *gin.Context, defect domain.Defect) []g
 }
 
 func (h *DefectHandler) mapSingleAttachment(c *gin.Context, defectID string, att domain.Attachment) gin.H {
-	url := h.buildDownloadURL(c, defectID, att)
-	return gin.H{
-		"id":          att.ID,
-		"filename":    att.Filename,
-		"contentType": att.ContentType,
-		"sizeBytes":   att.SizeBytes,
-		"storageKey":  att.StorageKey,
-		"uploadedAt":  att.UploadedAt,
-		"downloadUrl": url,
+	url := h.buildDownloadURL(defectID, att)
+	result := gin.H{
+		"id":            att.ID,
+		"filename":      att.Filename,
+		"contentType":   att.ContentType,
+		"sizeBytes":     att.SizeBytes,
+		"storageKey":    att.StorageKey,
+		"checksum":      att.Checksum,
+		"uploadedAt":    att.UploadedAt,
+		"downloadUrl":   url,
+		"thumbnailUrl":  nil,
+		"thumbnails":    gin.H{},
+		"takenAt":       att.Photo.TakenAt,
+		"latitude":      att.Photo.Latitude,
+		"longitude":     att.Photo.Longitude,
+		"cameraModel":   att.Photo.CameraModel,
+		"geotagged":     att.Photo.Geotagged(),
+		"uploadedBy":    att.UploadedBy,
+		"version":       att.Version,
+		"versionOf":     att.VersionOf,
+		"supersededAt":  att.SupersededAt,
+		"scanStatus":    att.ScanStatus,
+		"scanSignature": att.ScanSignature,
+	}
+	if att.HasThumbnails && att.CheckDownloadable() == nil {
+		thumbnails := gin.H{}
+		for _, size := range thumbnail.Sizes {
+			thumbnails[size.Name] = url + "/thumbnail?size=" + size.Name
+		}
+		result["thumbnailUrl"] = thumbnails[thumbnail.DefaultSize]
+		result["thumbnails"] = thumbnails
 	}
+	return result
 }
 
-func (h *DefectHandler) buildDownloadURL(c *gin.Context, defectID string, att domain.Attachment) string {
-	if url, err := h.storage.Presign(c.Request.Context(), att.StorageKey); err == nil && url != "" {
-		return url
-	}
+// buildDownloadURL always points to the API so that clients without direct
+// access to the storage (e.g. behind a proxy) can download files.
+func (h *DefectHandler) buildDownloadURL(defectID string, att domain.Attachment) string {
 	return fmt.Sprintf("/api/v1/defects/%s/attachments/%s", defectID, att.ID)
 }
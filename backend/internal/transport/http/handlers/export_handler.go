@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/pkg/export"
+	"defect-tracker/internal/transport/http/middleware"
+)
+
+// exportFileTimeout is the write deadline granted per archived file: an archive
+// of a whole project takes far longer than SERVER_WRITE_TIMEOUT.
+const exportFileTimeout = 10 * time.Minute
+
+func (h *DefectHandler) downloadAttachmentsZIP(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	items, err := h.service.ExportAttachments(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Дефект не найден"})
+		return
+	}
+	h.streamZIP(c, "defect-"+c.Param("id")+"-attachments.zip", items)
+}
+
+func (h *DefectHandler) downloadProjectAttachmentsZIP(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	items, err := h.service.ExportProjectAttachments(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось подготовить архив"})
+		return
+	}
+	h.streamZIP(c, "project-"+c.Param("id")+"-attachments.zip", items)
+}
+
+// streamZIP writes the archive straight into the response. Once the first byte
+// is sent the status cannot change, so a failure only truncates the archive
+// and is recorded on the context for the request log.
+func (h *DefectHandler) streamZIP(c *gin.Context, filename string, items []domain.ExportedAttachment) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+
+	controller := http.NewResponseController(c.Writer)
+	extend := func() {
+		_ = controller.SetWriteDeadline(time.Now().Add(exportFileTimeout))
+	}
+	if err := export.AttachmentsZIP(c.Request.Context(), c.Writer, h.storage, items, extend); err != nil {
+		_ = c.Error(err)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/service/user"
+	"defect-tracker/internal/transport/http/middleware"
+)
+
+// InvitationHandler lets project managers and administrators invite people
+// without an account; the invitees accept through AuthHandler.
+type InvitationHandler struct {
+	invitations *user.InvitationService
+}
+
+func NewInvitationHandler(invitations *user.InvitationService) *InvitationHandler {
+	return &InvitationHandler{invitations: invitations}
+}
+
+func (h *InvitationHandler) Register(rg *gin.RouterGroup) {
+	rg.GET("/projects/:id/invitations", h.list)
+	rg.POST("/projects/:id/invitations", h.create)
+	rg.DELETE("/projects/:id/invitations/:invitationId", h.revoke)
+}
+
+func (h *InvitationHandler) list(c *gin.Context) {
+	actor, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	invitations, err := h.invitations.ListPending(c.Request.Context(), actor, c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	items := make([]gin.H, 0, len(invitations))
+	for _, inv := range invitations {
+		items = append(items, mapInvitation(inv))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// create invites by email; inviting the same email to the project again
+// replaces the pending invitation and sends a fresh link.
+func (h *InvitationHandler) create(c *gin.Context) {
+	var payload struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	actor, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	inv, err := h.invitations.Invite(c.Request.Context(), actor, c.Param("id"), payload.Email, payload.Role)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, mapInvitation(inv))
+}
+
+func (h *InvitationHandler) revoke(c *gin.Context) {
+	actor, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	if err := h.invitations.Revoke(c.Request.Context(), actor, c.Param("id"), c.Param("invitationId")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Приглашение отозвано"})
+}
+
+func (h *InvitationHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNoProjectAccess):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Приглашать в проект может только менеджер проекта или администратор"})
+	case errors.Is(err, domain.ErrEmailAlreadyExists):
+		c.JSON(http.StatusConflict, gin.H{"message": "Пользователь с таким email уже зарегистрирован, добавьте его в участники проекта"})
+	case errors.Is(err, domain.ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Приглашение не найдено"})
+	case errors.Is(err, user.ErrInvalidEmail), errors.Is(err, user.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"message": "Проект не найден"})
+	}
+}
+
+func mapInvitation(inv domain.Invitation) gin.H {
+	return gin.H{
+		"id":          inv.ID,
+		"email":       inv.Email,
+		"projectId":   inv.ProjectID,
+		"projectName": inv.ProjectName,
+		"role":        inv.Role,
+		"invitedBy":   inv.InvitedBy,
+		"expiresAt":   inv.ExpiresAt,
+		"createdAt":   inv.CreatedAt,
+	}
+}
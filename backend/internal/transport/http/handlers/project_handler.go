@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -22,10 +23,26 @@ func NewProjectHandler(service *project.Service) *ProjectHandler {
 func (h *ProjectHandler) Register(rg *gin.RouterGroup) {
 	rg.GET("/projects", h.list)
 	rg.POST("/projects", h.create)
+	rg.GET("/projects/:id", h.get)
+	rg.PATCH("/projects/:id", h.update)
+	rg.DELETE("/projects/:id", h.delete)
+	rg.POST("/projects/:id/archive", h.archive)
+	rg.POST("/projects/:id/unarchive", h.unarchive)
+	rg.GET("/projects/:id/members", h.listMembers)
+	rg.POST("/projects/:id/members", h.addMember)
+	rg.PATCH("/projects/:id/members/:userId", h.updateMember)
+	rg.DELETE("/projects/:id/members/:userId", h.removeMember)
 }
 
 func (h *ProjectHandler) list(c *gin.Context) {
-	projects, err := h.service.List(c.Request.Context())
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	includeArchived := c.Query("includeArchived") == "true"
+	projects, err := h.service.List(c.Request.Context(), user.ID, includeArchived)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось получить проекты"})
 		return
@@ -53,8 +70,8 @@ func (h *ProjectHandler) create(c *gin.Context) {
 		return
 	}
 
-	if user.Role != "manager" {
-		c.JSON(http.StatusForbidden, gin.H{"message": "Создавать проекты может только менеджер"})
+	if !user.CanCreateProjects() {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Создавать проекты может только менеджер или администратор"})
 		return
 	}
 
@@ -85,6 +102,235 @@ func (h *ProjectHandler) create(c *gin.Context) {
 	c.JSON(http.StatusCreated, mapProject(projectEntity))
 }
 
+func (h *ProjectHandler) get(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	projectEntity, err := h.service.Get(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Проект не найден"})
+		return
+	}
+	c.JSON(http.StatusOK, mapProject(projectEntity))
+}
+
+func (h *ProjectHandler) update(c *gin.Context) {
+	var payload struct {
+		Name        *string `json:"name"`
+		Stage       *string `json:"stage"`
+		Description *string `json:"description"`
+		StartDate   *string `json:"startDate"`
+		EndDate     *string `json:"endDate"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	update := domain.ProjectUpdate{
+		Name:        payload.Name,
+		Stage:       payload.Stage,
+		Description: payload.Description,
+	}
+	if payload.StartDate != nil {
+		start, err := parseDateValue(*payload.StartDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректная дата начала"})
+			return
+		}
+		update.StartDate = start
+		update.ClearStartDate = start == nil
+	}
+	if payload.EndDate != nil {
+		end, err := parseDateValue(*payload.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректная дата окончания"})
+			return
+		}
+		update.EndDate = end
+		update.ClearEndDate = end == nil
+	}
+
+	projectEntity, err := h.service.Update(c.Request.Context(), c.Param("id"), user, update)
+	if err != nil {
+		h.respondProjectError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mapProject(projectEntity))
+}
+
+func (h *ProjectHandler) archive(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+func (h *ProjectHandler) unarchive(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+func (h *ProjectHandler) setArchived(c *gin.Context, archived bool) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	projectEntity, err := h.service.SetArchived(c.Request.Context(), c.Param("id"), user, archived)
+	if err != nil {
+		h.respondProjectError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mapProject(projectEntity))
+}
+
+func (h *ProjectHandler) delete(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), c.Param("id"), user); err != nil {
+		h.respondProjectError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Проект удалён"})
+}
+
+func (h *ProjectHandler) respondProjectError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNoProjectAccess):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Изменять проект может только менеджер проекта"})
+	case errors.Is(err, domain.ErrProjectHasOpenDefects):
+		c.JSON(http.StatusConflict, gin.H{"message": "В проекте есть незакрытые дефекты, удаление невозможно"})
+	case errors.Is(err, project.ErrValidation):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Название проекта обязательно"})
+	case errors.Is(err, project.ErrInvalidDates):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Дата окончания раньше даты начала"})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"message": "Проект не найден"})
+	}
+}
+
+func (h *ProjectHandler) listMembers(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	members, err := h.service.ListMembers(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось получить участников проекта"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": mapMembers(members)})
+}
+
+func (h *ProjectHandler) addMember(c *gin.Context) {
+	var payload struct {
+		UserID string `json:"userId"`
+		Role   string `json:"role"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	h.saveMember(c, payload.UserID, payload.Role, http.StatusCreated)
+}
+
+func (h *ProjectHandler) updateMember(c *gin.Context) {
+	var payload struct {
+		Role string `json:"role"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	h.saveMember(c, c.Param("userId"), payload.Role, http.StatusOK)
+}
+
+func (h *ProjectHandler) saveMember(c *gin.Context, userID, role string, status int) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	member, err := h.service.SaveMember(c.Request.Context(), c.Param("id"), user, userID, role)
+	if err != nil {
+		h.respondMemberError(c, err)
+		return
+	}
+	c.JSON(status, mapMember(member))
+}
+
+func (h *ProjectHandler) removeMember(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	if err := h.service.RemoveMember(c.Request.Context(), c.Param("id"), user, c.Param("userId")); err != nil {
+		h.respondMemberError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Участник удалён из проекта"})
+}
+
+func (h *ProjectHandler) respondMemberError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNoProjectAccess):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Управлять участниками может только менеджер проекта"})
+	case errors.Is(err, domain.ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Участник не найден"})
+	case errors.Is(err, project.ErrLastManager):
+		c.JSON(http.StatusConflict, gin.H{"message": "В проекте должен остаться хотя бы один менеджер"})
+	case errors.Is(err, project.ErrValidation):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Укажите пользователя и роль: manager, engineer или observer"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Не удалось сохранить участника проекта"})
+	}
+}
+
+func mapMembers(members []domain.ProjectMember) []gin.H {
+	result := make([]gin.H, 0, len(members))
+	for _, member := range members {
+		result = append(result, mapMember(member))
+	}
+	return result
+}
+
+func mapMember(m domain.ProjectMember) gin.H {
+	return gin.H{
+		"projectId": m.ProjectID,
+		"userId":    m.UserID,
+		"fullName":  m.FullName,
+		"email":     m.Email,
+		"role":      m.Role,
+	}
+}
+
 func mapProjects(projects []domain.Project) []gin.H {
 	result := make([]gin.H, 0, len(projects))
 	for _, project := range projects {
@@ -110,6 +356,9 @@ func mapProject(p domain.Project) gin.H {
 		"description": p.Description,
 		"startDate":   start,
 		"endDate":     end,
+		"workflowId":  p.WorkflowID,
+		"archived":    p.ArchivedAt != nil,
+		"archivedAt":  p.ArchivedAt,
 		"createdBy":   p.CreatedBy,
 		"createdAt":   p.CreatedAt,
 	}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/service/defect"
+	"defect-tracker/internal/service/upload"
+	"defect-tracker/internal/transport/http/middleware"
+)
+
+// Resumable uploads follow the tus semantics: the client learns the offset
+// with GET and sends the next chunk with PATCH and an Upload-Offset header.
+const headerUploadOffset = "Upload-Offset"
+
+func (h *DefectHandler) createUpload(c *gin.Context) {
+	var payload struct {
+		Filename   string `json:"filename"`
+		SizeBytes  int64  `json:"sizeBytes"`
+		ReplacesID string `json:"replacesId"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	created, err := h.uploads.Create(c.Request.Context(), user, c.Param("id"), payload.Filename, payload.SizeBytes, payload.ReplacesID)
+	if denyIfForbidden(c, err) || respondUploadError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.Header(headerUploadOffset, "0")
+	c.JSON(http.StatusCreated, mapUpload(created))
+}
+
+func (h *DefectHandler) getUpload(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	current, err := h.uploads.Get(c.Request.Context(), user, c.Param("id"), c.Param("uploadId"))
+	if respondUploadProgressError(c, err, current) {
+		return
+	}
+	c.Header(headerUploadOffset, strconv.FormatInt(current.Offset, 10))
+	c.JSON(http.StatusOK, mapUpload(current))
+}
+
+func (h *DefectHandler) writeUploadChunk(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader(headerUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Заголовок Upload-Offset обязателен"})
+		return
+	}
+	length := c.Request.ContentLength
+	if length < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"message": "Заголовок Content-Length обязателен"})
+		return
+	}
+
+	h.extendDeadlines(c)
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, length)
+
+	current, err := h.uploads.WriteChunk(c.Request.Context(), user, c.Param("id"), c.Param("uploadId"), offset, body, length)
+	if respondUploadProgressError(c, err, current) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось сохранить часть файла"})
+		return
+	}
+	c.Header(headerUploadOffset, strconv.FormatInt(current.Offset, 10))
+	c.JSON(http.StatusOK, mapUpload(current))
+}
+
+// completeUpload assembles the file and creates the attachment the same way
+// a single-request upload does.
+func (h *DefectHandler) completeUpload(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	h.extendDeadlines(c)
+	finished, saved, err := h.uploads.Complete(c.Request.Context(), user, c.Param("id"), c.Param("uploadId"))
+	if respondUploadProgressError(c, err, finished) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось собрать файл"})
+		return
+	}
+
+	object, err := h.storage.Open(c.Request.Context(), saved.Key)
+	if err != nil {
+		h.purgeBlob(c.Request.Context(), saved.Key, false)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось открыть файл"})
+		return
+	}
+	defer object.Close()
+
+	head := make([]byte, defect.SniffLength)
+	n, err := io.ReadFull(object, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		h.purgeBlob(c.Request.Context(), saved.Key, false)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось прочитать файл"})
+		return
+	}
+	contentType, err := defect.DetectContentType(head[:n])
+	if err != nil {
+		h.purgeBlob(c.Request.Context(), saved.Key, false)
+		respondUploadError(c, err)
+		return
+	}
+
+	h.attachSaved(c, user, finished.ReplacesID, finished.Filename, contentType, saved, object)
+}
+
+func (h *DefectHandler) abortUpload(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	err := h.uploads.Abort(c.Request.Context(), user, c.Param("id"), c.Param("uploadId"))
+	if respondUploadProgressError(c, err, domain.Upload{}) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось отменить загрузку"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// extendDeadlines lets a chunk or the assembly of a large file outlive
+// SERVER_READ_TIMEOUT and SERVER_WRITE_TIMEOUT on slow site connections.
+func (h *DefectHandler) extendDeadlines(c *gin.Context) {
+	deadline := time.Now().Add(h.uploads.ChunkTimeout())
+	controller := http.NewResponseController(c.Writer)
+	_ = controller.SetReadDeadline(deadline)
+	_ = controller.SetWriteDeadline(deadline)
+}
+
+// respondUploadProgressError maps resumable upload errors and reports whether
+// it responded. Conflicts carry the current offset so the client can resume.
+func respondUploadProgressError(c *gin.Context, err error, current domain.Upload) bool {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, upload.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Загрузка не найдена"})
+	case errors.Is(err, domain.ErrUploadExpired):
+		c.JSON(http.StatusGone, gin.H{"message": "Срок загрузки истёк, начните её заново"})
+	case errors.Is(err, domain.ErrUploadOffsetMismatch):
+		c.Header(headerUploadOffset, strconv.FormatInt(current.Offset, 10))
+		c.JSON(http.StatusConflict, gin.H{"message": "Смещение не совпадает с загруженным объёмом", "offset": current.Offset})
+	case errors.Is(err, domain.ErrUploadBusy):
+		c.JSON(http.StatusConflict, gin.H{"message": "Загрузка уже выполняется другим запросом", "offset": current.Offset})
+	case errors.Is(err, domain.ErrUploadChunkSize), errors.As(err, &tooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Размер части не совпадает с согласованным", "chunkSize": current.ChunkSize})
+	case errors.Is(err, domain.ErrUploadIncomplete):
+		c.JSON(http.StatusConflict, gin.H{"message": "Файл загружен не полностью", "offset": current.Offset})
+	default:
+		return denyIfForbidden(c, err) || respondUploadError(c, err)
+	}
+	return true
+}
+
+func mapUpload(u domain.Upload) gin.H {
+	return gin.H{
+		"id":         u.ID,
+		"defectId":   u.DefectID,
+		"filename":   u.Filename,
+		"sizeBytes":  u.SizeBytes,
+		"chunkSize":  u.ChunkSize,
+		"offset":     u.Offset,
+		"replacesId": u.ReplacesID,
+		"expiresAt":  u.ExpiresAt,
+	}
+}
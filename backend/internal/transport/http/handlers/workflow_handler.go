@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"defect-tracker/internal/domain"
+	"defect-tracker/internal/service/workflow"
+	"defect-tracker/internal/transport/http/middleware"
+)
+
+type WorkflowHandler struct {
+	service *workflow.Service
+}
+
+func NewWorkflowHandler(service *workflow.Service) *WorkflowHandler {
+	return &WorkflowHandler{service: service}
+}
+
+func (h *WorkflowHandler) Register(rg *gin.RouterGroup) {
+	rg.GET("/workflows", h.list)
+	rg.POST("/workflows", h.create)
+	rg.GET("/workflows/:id", h.get)
+	rg.GET("/projects/:id/workflow", h.getForProject)
+	rg.PUT("/projects/:id/workflow", h.assignToProject)
+}
+
+func (h *WorkflowHandler) list(c *gin.Context) {
+	workflows, err := h.service.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Не удалось получить workflow"})
+		return
+	}
+	result := make([]gin.H, 0, len(workflows))
+	for _, wf := range workflows {
+		result = append(result, mapWorkflow(wf))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": result})
+}
+
+func (h *WorkflowHandler) get(c *gin.Context) {
+	wf, err := h.service.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Workflow не найден"})
+		return
+	}
+	c.JSON(http.StatusOK, mapWorkflow(wf))
+}
+
+func (h *WorkflowHandler) create(c *gin.Context) {
+	var payload struct {
+		Name     string `json:"name"`
+		Statuses []struct {
+			Code      string `json:"code"`
+			Name      string `json:"name"`
+			IsInitial bool   `json:"isInitial"`
+			IsFinal   bool   `json:"isFinal"`
+			Position  int    `json:"position"`
+		} `json:"statuses"`
+		Transitions []struct {
+			From           string   `json:"from"`
+			To             string   `json:"to"`
+			AllowedRoles   []string `json:"allowedRoles"`
+			RequiredFields []string `json:"requiredFields"`
+		} `json:"transitions"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Некорректный формат данных"})
+		return
+	}
+
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	if !user.CanCreateProjects() {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Настраивать workflow может только менеджер или администратор"})
+		return
+	}
+
+	create := domain.WorkflowCreate{Name: payload.Name, CreatedBy: user.ID}
+	for _, status := range payload.Statuses {
+		create.Statuses = append(create.Statuses, domain.WorkflowStatus{
+			Code:      status.Code,
+			Name:      status.Name,
+			IsInitial: status.IsInitial,
+			IsFinal:   status.IsFinal,
+			Position:  status.Position,
+		})
+	}
+	for _, transition := range payload.Transitions {
+		create.Transitions = append(create.Transitions, domain.WorkflowTransition{
+			From:           transition.From,
+			To:             transition.To,
+			AllowedRoles:   transition.AllowedRoles,
+			RequiredFields: transition.RequiredFields,
+		})
+	}
+
+	wf, err := h.service.Create(c.Request.Context(), create)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, mapWorkflow(wf))
+}
+
+func (h *WorkflowHandler) getForProject(c *gin.Context) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	wf, err := h.service.ProjectWorkflow(c.Request.Context(), c.Param("id"), user)
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Проект не найден"})
+		return
+	}
+	c.JSON(http.StatusOK, mapWorkflow(wf))
+}
+
+func (h *WorkflowHandler) assignToProject(c *gin.Context) {
+	var payload struct {
+		WorkflowID string `json:"workflowId"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil || payload.WorkflowID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Не указан workflow"})
+		return
+	}
+
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Необходима авторизация"})
+		return
+	}
+
+	wf, err := h.service.AssignToProject(c.Request.Context(), c.Param("id"), user, payload.WorkflowID)
+	if errors.Is(err, domain.ErrNoProjectAccess) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Менять workflow проекта может только менеджер проекта"})
+		return
+	}
+	if denyIfForbidden(c, err) {
+		return
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrWorkflowMismatch) {
+			c.JSON(http.StatusConflict, gin.H{"message": "В проекте есть дефекты в статусах, которых нет в выбранном workflow"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, mapWorkflow(wf))
+}
+
+func mapWorkflow(wf domain.Workflow) gin.H {
+	statuses := make([]gin.H, 0, len(wf.Statuses))
+	for _, status := range wf.Statuses {
+		statuses = append(statuses, gin.H{
+			"code":      status.Code,
+			"name":      status.Name,
+			"isInitial": status.IsInitial,
+			"isFinal":   status.IsFinal,
+			"position":  status.Position,
+		})
+	}
+	transitions := make([]gin.H, 0, len(wf.Transitions))
+	for _, transition := range wf.Transitions {
+		transitions = append(transitions, gin.H{
+			"from":           transition.From,
+			"to":             transition.To,
+			"allowedRoles":   transition.AllowedRoles,
+			"requiredFields": transition.RequiredFields,
+		})
+	}
+	return gin.H{
+		"id":          wf.ID,
+		"name":        wf.Name,
+		"isDefault":   wf.IsDefault,
+		"createdAt":   wf.CreatedAt,
+		"statuses":    statuses,
+		"transitions": transitions,
+	}
+}
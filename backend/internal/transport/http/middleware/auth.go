@@ -44,6 +44,12 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Пользователь не найден"})
 			return
 		}
+		// The user is loaded on every request, so deactivation takes effect
+		// before the access token expires.
+		if !userEntity.IsActive {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Учётная запись заблокирована"})
+			return
+		}
 
 		c.Set(userContextKey, userEntity)
 		c.Next()
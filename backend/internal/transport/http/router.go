@@ -16,6 +16,9 @@ func NewRouter(
 	authMW *middleware.AuthMiddleware,
 	defectHandler *handlers.DefectHandler,
 	projectHandler *handlers.ProjectHandler,
+	workflowHandler *handlers.WorkflowHandler,
+	invitationHandler *handlers.InvitationHandler,
+	adminHandler *handlers.AdminHandler,
 ) *gin.Engine {
 	router := gin.New()
 	router.Use(gin.Logger())
@@ -42,7 +45,10 @@ func NewRouter(
 
 		authHandler.RegisterProtected(secured)
 		projectHandler.Register(secured)
+		workflowHandler.Register(secured)
 		defectHandler.Register(secured)
+		invitationHandler.Register(secured)
+		adminHandler.Register(secured, authMW)
 	}
 
 	return router
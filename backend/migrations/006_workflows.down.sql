@@ -0,0 +1,11 @@
+CREATE TYPE defect_status AS ENUM ('NEW', 'IN_PROGRESS', 'IN_REVIEW', 'CLOSED', 'CANCELED');
+
+ALTER TABLE defects ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE defects ALTER COLUMN status TYPE defect_status USING status::defect_status;
+ALTER TABLE defects ALTER COLUMN status SET DEFAULT 'NEW';
+
+ALTER TABLE projects DROP COLUMN IF EXISTS workflow_id;
+
+DROP TABLE IF EXISTS workflow_transitions;
+DROP TABLE IF EXISTS workflow_statuses;
+DROP TABLE IF EXISTS workflows;
@@ -0,0 +1,61 @@
+-- Настраиваемые workflow статусов дефектов (по проектам)
+CREATE TABLE workflows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_workflows_default ON workflows(is_default) WHERE is_default;
+
+CREATE TABLE workflow_statuses (
+    workflow_id UUID NOT NULL REFERENCES workflows(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    name TEXT NOT NULL,
+    is_initial BOOLEAN NOT NULL DEFAULT FALSE,
+    is_final BOOLEAN NOT NULL DEFAULT FALSE,
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (workflow_id, code)
+);
+
+CREATE UNIQUE INDEX idx_workflow_statuses_initial ON workflow_statuses(workflow_id) WHERE is_initial;
+
+-- allowed_roles пустой = переход доступен любой роли
+CREATE TABLE workflow_transitions (
+    workflow_id UUID NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    allowed_roles TEXT[] NOT NULL DEFAULT '{}',
+    required_fields TEXT[] NOT NULL DEFAULT '{}',
+    PRIMARY KEY (workflow_id, from_status, to_status),
+    FOREIGN KEY (workflow_id, from_status) REFERENCES workflow_statuses(workflow_id, code) ON DELETE CASCADE,
+    FOREIGN KEY (workflow_id, to_status) REFERENCES workflow_statuses(workflow_id, code) ON DELETE CASCADE
+);
+
+ALTER TABLE projects ADD COLUMN workflow_id UUID REFERENCES workflows(id);
+
+-- Статус становится строкой из workflow проекта вместо enum
+ALTER TABLE defects ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE defects ALTER COLUMN status TYPE TEXT USING status::text;
+ALTER TABLE defects ALTER COLUMN status SET DEFAULT 'NEW';
+DROP TYPE IF EXISTS defect_status;
+
+-- Workflow по умолчанию повторяет прежний жёстко заданный процесс
+INSERT INTO workflows (id, name, is_default)
+VALUES ('f0000000-0000-0000-0000-000000000001', 'Стандартный', TRUE);
+
+INSERT INTO workflow_statuses (workflow_id, code, name, is_initial, is_final, position) VALUES
+  ('f0000000-0000-0000-0000-000000000001', 'NEW', 'Новый', TRUE, FALSE, 1),
+  ('f0000000-0000-0000-0000-000000000001', 'IN_PROGRESS', 'В работе', FALSE, FALSE, 2),
+  ('f0000000-0000-0000-0000-000000000001', 'IN_REVIEW', 'На проверке', FALSE, FALSE, 3),
+  ('f0000000-0000-0000-0000-000000000001', 'CLOSED', 'Закрыт', FALSE, TRUE, 4),
+  ('f0000000-0000-0000-0000-000000000001', 'CANCELED', 'Отменён', FALSE, TRUE, 5);
+
+INSERT INTO workflow_transitions (workflow_id, from_status, to_status, allowed_roles) VALUES
+  ('f0000000-0000-0000-0000-000000000001', 'NEW', 'IN_PROGRESS', '{}'),
+  ('f0000000-0000-0000-0000-000000000001', 'NEW', 'CANCELED', '{manager}'),
+  ('f0000000-0000-0000-0000-000000000001', 'IN_PROGRESS', 'IN_REVIEW', '{}'),
+  ('f0000000-0000-0000-0000-000000000001', 'IN_PROGRESS', 'CANCELED', '{manager}'),
+  ('f0000000-0000-0000-0000-000000000001', 'IN_REVIEW', 'CLOSED', '{manager}'),
+  ('f0000000-0000-0000-0000-000000000001', 'IN_REVIEW', 'CANCELED', '{manager}');
@@ -0,0 +1,16 @@
+UPDATE defects SET status = 'IN_PROGRESS' WHERE status = 'REOPENED';
+
+DELETE FROM workflow_transitions
+WHERE workflow_id = 'f0000000-0000-0000-0000-000000000001'
+  AND (from_status, to_status) IN (
+    ('IN_REVIEW', 'IN_PROGRESS'),
+    ('CLOSED', 'REOPENED'),
+    ('REOPENED', 'IN_PROGRESS'),
+    ('REOPENED', 'CANCELED')
+  );
+
+DELETE FROM workflow_statuses
+WHERE workflow_id = 'f0000000-0000-0000-0000-000000000001' AND code = 'REOPENED';
+
+ALTER TABLE defects DROP COLUMN IF EXISTS reopen_count;
+ALTER TABLE defect_history DROP COLUMN IF EXISTS reason;
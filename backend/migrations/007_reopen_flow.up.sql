@@ -0,0 +1,14 @@
+ALTER TABLE defect_history ADD COLUMN reason TEXT;
+ALTER TABLE defects ADD COLUMN reopen_count INT NOT NULL DEFAULT 0;
+
+-- Повторное открытие закрытых дефектов и возврат с проверки в работу
+INSERT INTO workflow_statuses (workflow_id, code, name, is_initial, is_final, position)
+VALUES ('f0000000-0000-0000-0000-000000000001', 'REOPENED', 'Переоткрыт', FALSE, FALSE, 6)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO workflow_transitions (workflow_id, from_status, to_status, allowed_roles, required_fields) VALUES
+  ('f0000000-0000-0000-0000-000000000001', 'IN_REVIEW', 'IN_PROGRESS', '{manager}', '{reason}'),
+  ('f0000000-0000-0000-0000-000000000001', 'CLOSED', 'REOPENED', '{manager}', '{reason}'),
+  ('f0000000-0000-0000-0000-000000000001', 'REOPENED', 'IN_PROGRESS', '{}', '{}'),
+  ('f0000000-0000-0000-0000-000000000001', 'REOPENED', 'CANCELED', '{manager}', '{}')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_projects_active;
+
+ALTER TABLE projects DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,3 @@
+ALTER TABLE projects ADD COLUMN archived_at TIMESTAMPTZ;
+
+CREATE INDEX idx_projects_active ON projects(created_at DESC) WHERE archived_at IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_defect_comments_search;
+DROP INDEX IF EXISTS idx_defects_search;
+
+ALTER TABLE defect_comments DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE defects DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,12 @@
+-- Полнотекстовый поиск по дефектам и комментариям (русская морфология)
+ALTER TABLE defects ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('russian', COALESCE(description, '')), 'B')
+    ) STORED;
+
+ALTER TABLE defect_comments ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('russian', body)) STORED;
+
+CREATE INDEX idx_defects_search ON defects USING GIN (search_vector);
+CREATE INDEX idx_defect_comments_search ON defect_comments USING GIN (search_vector);
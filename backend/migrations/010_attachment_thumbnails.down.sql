@@ -0,0 +1 @@
+ALTER TABLE defect_attachments DROP COLUMN IF EXISTS has_thumbnails;
@@ -0,0 +1,2 @@
+-- Превью фотографий хранятся рядом с оригиналом под производными ключами
+ALTER TABLE defect_attachments ADD COLUMN has_thumbnails BOOLEAN NOT NULL DEFAULT FALSE;
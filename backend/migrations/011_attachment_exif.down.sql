@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_defect_attachments_geotagged;
+
+ALTER TABLE defect_attachments
+    DROP COLUMN IF EXISTS camera_model,
+    DROP COLUMN IF EXISTS gps_longitude,
+    DROP COLUMN IF EXISTS gps_latitude,
+    DROP COLUMN IF EXISTS taken_at;
@@ -0,0 +1,8 @@
+-- EXIF фотографий: когда, где и чем сделан снимок
+ALTER TABLE defect_attachments
+    ADD COLUMN taken_at TIMESTAMPTZ,
+    ADD COLUMN gps_latitude DOUBLE PRECISION,
+    ADD COLUMN gps_longitude DOUBLE PRECISION,
+    ADD COLUMN camera_model TEXT;
+
+CREATE INDEX idx_defect_attachments_geotagged ON defect_attachments(defect_id) WHERE gps_latitude IS NOT NULL;
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_defect_attachments_version_of;
+DROP INDEX IF EXISTS idx_defect_attachments_current;
+
+DELETE FROM defect_attachments WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE defect_attachments
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS superseded_at,
+    DROP COLUMN IF EXISTS version_of,
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS uploaded_by;
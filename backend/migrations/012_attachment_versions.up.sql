@@ -0,0 +1,12 @@
+-- Автор вложения, версии при замене файла и мягкое удаление
+ALTER TABLE defect_attachments
+    ADD COLUMN uploaded_by UUID REFERENCES users(id),
+    ADD COLUMN version INT NOT NULL DEFAULT 1,
+    ADD COLUMN version_of UUID REFERENCES defect_attachments(id),
+    ADD COLUMN superseded_at TIMESTAMPTZ,
+    ADD COLUMN deleted_at TIMESTAMPTZ,
+    ADD COLUMN deleted_by UUID REFERENCES users(id);
+
+CREATE INDEX idx_defect_attachments_current ON defect_attachments(defect_id, created_at DESC)
+    WHERE deleted_at IS NULL AND superseded_at IS NULL;
+CREATE INDEX idx_defect_attachments_version_of ON defect_attachments(version_of) WHERE version_of IS NOT NULL;
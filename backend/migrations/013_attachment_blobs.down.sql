@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS attachment_blobs;
+DROP INDEX IF EXISTS idx_defect_attachments_storage_key;
+
+ALTER TABLE defect_attachments DROP COLUMN IF EXISTS checksum_sha256;
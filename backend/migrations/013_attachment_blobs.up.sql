@@ -0,0 +1,20 @@
+-- Контрольные суммы вложений и общие файлы для одинакового содержимого
+ALTER TABLE defect_attachments ADD COLUMN checksum_sha256 TEXT;
+
+CREATE TABLE attachment_blobs (
+    storage_key TEXT PRIMARY KEY,
+    checksum_sha256 TEXT UNIQUE,
+    size_bytes BIGINT NOT NULL,
+    has_thumbnails BOOLEAN NOT NULL DEFAULT FALSE,
+    ref_count INT NOT NULL CHECK (ref_count >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_defect_attachments_storage_key ON defect_attachments(storage_key);
+
+-- Уже загруженные файлы без контрольной суммы не участвуют в дедупликации
+INSERT INTO attachment_blobs (storage_key, size_bytes, has_thumbnails, ref_count)
+SELECT storage_key, MAX(size_bytes), BOOL_OR(has_thumbnails), COUNT(*)
+FROM defect_attachments
+WHERE deleted_at IS NULL
+GROUP BY storage_key;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS attachment_upload_parts;
+DROP TABLE IF EXISTS attachment_uploads;
@@ -0,0 +1,27 @@
+-- Возобновляемые загрузки вложений по частям
+CREATE TABLE attachment_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    defect_id UUID NOT NULL REFERENCES defects(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    chunk_size BIGINT NOT NULL CHECK (chunk_size > 0),
+    offset_bytes BIGINT NOT NULL DEFAULT 0,
+    replaces_id UUID REFERENCES defect_attachments(id),
+    storage_key TEXT NOT NULL,
+    storage_upload_id TEXT NOT NULL,
+    hash_state BYTEA,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE TABLE attachment_upload_parts (
+    upload_id UUID NOT NULL REFERENCES attachment_uploads(id) ON DELETE CASCADE,
+    number INT NOT NULL,
+    etag TEXT NOT NULL DEFAULT '',
+    size_bytes BIGINT NOT NULL,
+    PRIMARY KEY (upload_id, number)
+);
+
+CREATE INDEX idx_attachment_uploads_expires_at ON attachment_uploads(expires_at);
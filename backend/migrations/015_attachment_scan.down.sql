@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_defect_attachments_pending_scan;
+
+ALTER TABLE defect_attachments
+    DROP COLUMN IF EXISTS scanned_at,
+    DROP COLUMN IF EXISTS scan_signature,
+    DROP COLUMN IF EXISTS scan_status;
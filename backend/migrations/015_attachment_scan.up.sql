@@ -0,0 +1,12 @@
+-- Антивирусная проверка вложений: файл доступен для скачивания только после проверки
+ALTER TABLE defect_attachments
+    ADD COLUMN scan_status TEXT NOT NULL DEFAULT 'clean'
+        CHECK (scan_status IN ('pending_scan', 'clean', 'quarantined')),
+    ADD COLUMN scan_signature TEXT,
+    ADD COLUMN scanned_at TIMESTAMPTZ;
+
+-- Уже загруженные файлы считаются проверенными, новые ждут проверки.
+ALTER TABLE defect_attachments ALTER COLUMN scan_status SET DEFAULT 'pending_scan';
+
+CREATE INDEX idx_defect_attachments_pending_scan ON defect_attachments(created_at)
+    WHERE scan_status = 'pending_scan' AND deleted_at IS NULL;
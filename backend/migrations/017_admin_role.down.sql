@@ -0,0 +1,13 @@
+-- Значение из ENUM удалить нельзя: администраторы становятся менеджерами, тип пересоздаётся
+UPDATE users SET role = 'manager' WHERE role = 'admin';
+UPDATE project_members SET role = 'manager' WHERE role = 'admin';
+
+ALTER TYPE user_role RENAME TO user_role_old;
+CREATE TYPE user_role AS ENUM ('manager', 'engineer', 'observer');
+
+ALTER TABLE users ALTER COLUMN role DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN role TYPE user_role USING role::text::user_role;
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'engineer';
+ALTER TABLE project_members ALTER COLUMN role TYPE user_role USING role::text::user_role;
+
+DROP TYPE user_role_old;
@@ -0,0 +1,2 @@
+-- Роль администратора для управления учётными записями
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'admin';
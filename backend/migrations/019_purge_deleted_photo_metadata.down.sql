@@ -0,0 +1 @@
+-- Стёртые метаданные не восстанавливаются
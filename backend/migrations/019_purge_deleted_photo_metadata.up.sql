@@ -0,0 +1,4 @@
+-- Удалённые вложения не должны хранить персональные данные из EXIF: координаты, время съёмки и модель камеры
+UPDATE defect_attachments
+SET gps_latitude = NULL, gps_longitude = NULL, taken_at = NULL, camera_model = NULL
+WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,5 @@
+UPDATE defect_attachments SET scan_status = 'pending_scan', scanned_at = NULL WHERE scan_status = 'missing';
+
+ALTER TABLE defect_attachments DROP CONSTRAINT IF EXISTS defect_attachments_scan_status_check;
+ALTER TABLE defect_attachments ADD CONSTRAINT defect_attachments_scan_status_check
+    CHECK (scan_status IN ('pending_scan', 'clean', 'quarantined'));
@@ -0,0 +1,4 @@
+-- Вложения, файл которых пропал из хранилища до проверки, больше не ждут антивирус
+ALTER TABLE defect_attachments DROP CONSTRAINT IF EXISTS defect_attachments_scan_status_check;
+ALTER TABLE defect_attachments ADD CONSTRAINT defect_attachments_scan_status_check
+    CHECK (scan_status IN ('pending_scan', 'clean', 'quarantined', 'missing'));
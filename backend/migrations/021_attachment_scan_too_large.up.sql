@@ -0,0 +1,4 @@
+-- Файлы больше лимита антивируса получают окончательный статус и не проверяются повторно
+ALTER TABLE defect_attachments DROP CONSTRAINT IF EXISTS defect_attachments_scan_status_check;
+ALTER TABLE defect_attachments ADD CONSTRAINT defect_attachments_scan_status_check
+    CHECK (scan_status IN ('pending_scan', 'clean', 'quarantined', 'missing', 'too_large'));
@@ -44,12 +44,57 @@ const api = {
   changePassword(payload) {
     return client.post('/auth/password', payload)
   },
+  forgotPassword(payload) {
+    return client.post('/auth/password/forgot', payload)
+  },
+  resetPassword(payload) {
+    return client.post('/auth/password/reset', payload)
+  },
+  lookupInvitation(payload) {
+    return client.post('/auth/invitations/lookup', payload)
+  },
+  acceptInvitation(payload) {
+    return client.post('/auth/invitations/accept', payload)
+  },
   getProjects(params = {}) {
     return client.get('/projects', { params })
   },
   createProject(payload) {
     return client.post('/projects', payload)
   },
+  updateProject(id, payload) {
+    return client.patch(`/projects/${id}`, payload)
+  },
+  archiveProject(id) {
+    return client.post(`/projects/${id}/archive`)
+  },
+  unarchiveProject(id) {
+    return client.post(`/projects/${id}/unarchive`)
+  },
+  deleteProject(id) {
+    return client.delete(`/projects/${id}`)
+  },
+  getProjectMembers(id) {
+    return client.get(`/projects/${id}/members`)
+  },
+  addProjectMember(id, payload) {
+    return client.post(`/projects/${id}/members`, payload)
+  },
+  updateProjectMember(id, userId, payload) {
+    return client.patch(`/projects/${id}/members/${userId}`, payload)
+  },
+  removeProjectMember(id, userId) {
+    return client.delete(`/projects/${id}/members/${userId}`)
+  },
+  getProjectInvitations(id) {
+    return client.get(`/projects/${id}/invitations`)
+  },
+  inviteToProject(id, payload) {
+    return client.post(`/projects/${id}/invitations`, payload)
+  },
+  revokeProjectInvitation(id, invitationId) {
+    return client.delete(`/projects/${id}/invitations/${invitationId}`)
+  },
   getDefects(params = {}) {
     return client.get('/defects', { params })
   },
@@ -59,6 +104,12 @@ const api = {
   getDefect(id) {
     return client.get(`/defects/${id}`)
   },
+  updateDefect(id, payload) {
+    return client.patch(`/defects/${id}`, payload)
+  },
+  getDefectHistory(id, params = {}) {
+    return client.get(`/defects/${id}/history`, { params })
+  },
   getDefectComments(id) {
     return client.get(`/defects/${id}/comments`)
   },
@@ -72,6 +123,37 @@ const api = {
       headers: { 'Content-Type': 'multipart/form-data' },
     })
   },
+  deleteDefectAttachment(id, attachmentId) {
+    return client.delete(`/defects/${id}/attachments/${attachmentId}`)
+  },
+  replaceDefectAttachment(id, attachmentId, file) {
+    const formData = new FormData()
+    formData.append('file', file)
+    return client.post(`/defects/${id}/attachments/${attachmentId}/versions`, formData, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    })
+  },
+  getDefectAttachmentVersions(id, attachmentId) {
+    return client.get(`/defects/${id}/attachments/${attachmentId}/versions`)
+  },
+  downloadDefectAttachment(id, attachmentId) {
+    return client.get(`/defects/${id}/attachments/${attachmentId}`, {
+      responseType: 'blob',
+      timeout: 0,
+    })
+  },
+  downloadDefectAttachmentsZip(id) {
+    return client.get(`/defects/${id}/attachments.zip`, {
+      responseType: 'blob',
+      timeout: 0,
+    })
+  },
+  downloadProjectAttachmentsZip(id) {
+    return client.get(`/projects/${id}/attachments.zip`, {
+      responseType: 'blob',
+      timeout: 0,
+    })
+  },
 }
 
 export default api
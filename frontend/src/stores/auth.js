@@ -88,6 +88,33 @@ export const useAuthStore = defineStore('auth', {
     async changePassword(currentPassword, newPassword) {
       return api.changePassword({ currentPassword, newPassword })
     },
+    async forgotPassword(email) {
+      const { data } = await api.forgotPassword({ email })
+      return data
+    },
+    async resetPassword(token, newPassword) {
+      const { data } = await api.resetPassword({ token, newPassword })
+      return data
+    },
+    async lookupInvitation(token) {
+      const { data } = await api.lookupInvitation({ token })
+      return data
+    },
+    async acceptInvitation(payload) {
+      this.loading = true
+      this.error = null
+      try {
+        const { data } = await api.acceptInvitation(payload)
+        this.persistSession(data)
+        return data
+      } catch (error) {
+        this.error =
+          error?.response?.data?.message || 'Не удалось принять приглашение.'
+        throw error
+      } finally {
+        this.loading = false
+      }
+    },
     persistSession(data) {
       this.token = data.accessToken
       this.refreshToken = data.refreshToken
@@ -60,5 +60,22 @@ export const useDefectsStore = defineStore('defects', {
       }
       return data
     },
+    async downloadAttachment(id, attachment) {
+      const { data } = await api.downloadDefectAttachment(id, attachment.id)
+      saveBlob(data, attachment.filename)
+    },
+    async downloadAttachmentsZip(id) {
+      const { data } = await api.downloadDefectAttachmentsZip(id)
+      saveBlob(data, `defect-${id}-attachments.zip`)
+    },
   },
 })
+
+function saveBlob(blob, filename) {
+  const url = URL.createObjectURL(blob)
+  const link = document.createElement('a')
+  link.href = url
+  link.download = filename
+  link.click()
+  URL.revokeObjectURL(url)
+}